@@ -0,0 +1,10 @@
+package apis
+
+import (
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+)
+
+func init() {
+	// Register the prometheus-operator types used for the system ServiceMonitor and PrometheusRule
+	AddToSchemes = append(AddToSchemes, monitoringv1.AddToScheme)
+}
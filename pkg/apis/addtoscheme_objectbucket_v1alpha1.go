@@ -0,0 +1,10 @@
+package apis
+
+import (
+	obAPI "github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+)
+
+func init() {
+	// Register the object bucket types used to map buckets to their claims
+	AddToSchemes = append(AddToSchemes, obAPI.AddToScheme)
+}
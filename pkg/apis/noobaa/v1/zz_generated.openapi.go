@@ -0,0 +1,509 @@
+// +build !
+
+// This file was autogenerated by openapi-gen. Do not edit it manually!
+
+package v1
+
+import (
+	spec "github.com/go-openapi/spec"
+	common "k8s.io/kube-openapi/pkg/common"
+)
+
+func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
+	return map[string]common.OpenAPIDefinition{
+		"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.BackingStore":       schema_pkg_apis_noobaa_v1_BackingStore(ref),
+		"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.BackingStoreSpec":   schema_pkg_apis_noobaa_v1_BackingStoreSpec(ref),
+		"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.BackingStoreStatus": schema_pkg_apis_noobaa_v1_BackingStoreStatus(ref),
+		"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.BucketClass":        schema_pkg_apis_noobaa_v1_BucketClass(ref),
+		"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.BucketClassSpec":    schema_pkg_apis_noobaa_v1_BucketClassSpec(ref),
+		"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.BucketClassStatus":  schema_pkg_apis_noobaa_v1_BucketClassStatus(ref),
+		"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.NooBaa":             schema_pkg_apis_noobaa_v1_NooBaa(ref),
+		"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.NooBaaSpec":         schema_pkg_apis_noobaa_v1_NooBaaSpec(ref),
+		"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.NooBaaStatus":       schema_pkg_apis_noobaa_v1_NooBaaStatus(ref),
+	}
+}
+
+func schema_pkg_apis_noobaa_v1_BackingStore(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "BackingStore is the Schema for the backingstores API",
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.BackingStoreSpec"),
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.BackingStoreStatus"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.BackingStoreSpec", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.BackingStoreStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+	}
+}
+
+func schema_pkg_apis_noobaa_v1_BackingStoreSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "BackingStoreSpec defines the desired state of BackingStore",
+				Properties: map[string]spec.Schema{
+					"type": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Type is the type of the backing store",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"bucketName": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"secret": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Secret refers to a secret that provides the credentials",
+							Ref:         ref("k8s.io/api/core/v1.SecretReference"),
+						},
+					},
+					"s3Options": {
+						SchemaProps: spec.SchemaProps{
+							Description: "S3Options specifies client options for the backing store",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.S3Options"),
+						},
+					},
+					"caBundle": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CABundle (optional) is a config map with PEM encoded CA certificates to trust when connecting to the backing store. Note that the certificates are added to the trust store of the server, so they are trusted for all connections.",
+							Ref:         ref("k8s.io/api/core/v1.LocalObjectReference"),
+						},
+					},
+				},
+				Required: []string{"type", "bucketName", "secret"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.S3Options", "k8s.io/api/core/v1.LocalObjectReference", "k8s.io/api/core/v1.SecretReference"},
+	}
+}
+
+func schema_pkg_apis_noobaa_v1_BackingStoreStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "BackingStoreStatus defines the observed state of BackingStore",
+				Properties:  map[string]spec.Schema{},
+			},
+		},
+		Dependencies: []string{},
+	}
+}
+
+func schema_pkg_apis_noobaa_v1_BucketClass(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "BucketClass is the Schema for the bucketclasses API",
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.BucketClassSpec"),
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.BucketClassStatus"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.BucketClassSpec", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.BucketClassStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+	}
+}
+
+func schema_pkg_apis_noobaa_v1_BucketClassSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "BucketClassSpec defines the desired state of BucketClass",
+				Properties:  map[string]spec.Schema{},
+			},
+		},
+		Dependencies: []string{},
+	}
+}
+
+func schema_pkg_apis_noobaa_v1_BucketClassStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "BucketClassStatus defines the observed state of BucketClass",
+				Properties:  map[string]spec.Schema{},
+			},
+		},
+		Dependencies: []string{},
+	}
+}
+
+func schema_pkg_apis_noobaa_v1_NooBaa(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "NooBaa is the Schema for the NooBaas API",
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Standard object metadata.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Specification of the desired behavior of the noobaa system.",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.NooBaaSpec"),
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Most recently observed status of the noobaa system.",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.NooBaaStatus"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.NooBaaSpec", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.NooBaaStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+	}
+}
+
+func schema_pkg_apis_noobaa_v1_NooBaaSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "NooBaaSpec defines the desired state of System",
+				Properties: map[string]spec.Schema{
+					"image": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Image (optional) overrides the default image for server container",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"mongoImage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "MongoImage (optional) overrides the default image for mongodb container",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"imagePullSecret": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ImagePullSecret (optional) sets a pull secret for the system image",
+							Ref:         ref("k8s.io/api/core/v1.LocalObjectReference"),
+						},
+					},
+					"storageClassName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "StorageClassName (optional) overrides the default StorageClass for the PVC that the operator creates, this affects where the system stores its database which contains system config, buckets, objects meta-data and mapping file parts to storage locations.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"coreResources": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CoreResources (optional) overrides the default resource requirements for the server container",
+							Ref:         ref("k8s.io/api/core/v1.ResourceRequirements"),
+						},
+					},
+					"dbResources": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DBResources (optional) overrides the default resource requirements for the mongodb container",
+							Ref:         ref("k8s.io/api/core/v1.ResourceRequirements"),
+						},
+					},
+					"dbVolumeResources": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DBVolumeResources (optional) overrides the default PVC resource requirements for the database volume. For an existing system the PVC storage request can only grow, and requires a StorageClass that allows volume expansion.",
+							Ref:         ref("k8s.io/api/core/v1.ResourceRequirements"),
+						},
+					},
+					"tolerations": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Tolerations (optional) passed through to the pods of the system",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.Toleration"),
+									},
+								},
+							},
+						},
+					},
+					"affinity": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Affinity (optional) passed through to the pods of the system",
+							Ref:         ref("k8s.io/api/core/v1.Affinity"),
+						},
+					},
+					"nodeSelector": {
+						SchemaProps: spec.SchemaProps{
+							Description: "NodeSelector (optional) passed through to the pods of the system",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"priorityClassName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PriorityClassName (optional) passed through to the pods of the system",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"services": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Services (optional) configures the mgmt and s3 services of the system",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.ServicesSpec"),
+						},
+					},
+					"dbType": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DBType (optional) selects the database of the system. \"mongodb\" (default) runs a mongodb container in the core pod with a PVC for its data, \"external\" connects to an existing MongoDB server using the url from ExternalDBSecret. The DBType cannot be changed after the system is created.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"externalDBSecret": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ExternalDBSecret (optional) is required when DBType is \"external\". The secret should contain the MongoDB connection url in the \"db_url\" key, and can contain a TLS CA bundle in the \"ca.crt\" key which will be mounted to the core pod in /etc/mongodb-tls/ca.crt and added to the url as the tlsCAFile option (unless the url already sets tlsCAFile or sslCA).",
+							Ref:         ref("k8s.io/api/core/v1.LocalObjectReference"),
+						},
+					},
+					"dbBackup": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DBBackup (optional) schedules periodic backups of the system database using a CronJob that runs `noobaa system backup` from the operator image. Only supported with the embedded mongodb (DBType \"mongodb\").",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.DBBackupSpec"),
+						},
+					},
+					"recoverySecret": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RecoverySecret (optional) is a secret with the \"email\" and \"password\" of an admin account of the system. It is used to recover the operator credentials if the operator secret was lost and the admin secret of the system cannot be used to authenticate.",
+							Ref:         ref("k8s.io/api/core/v1.LocalObjectReference"),
+						},
+					},
+					"cleanupPolicy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CleanupPolicy (optional) selects what the operator deletes when the system is deleted, in addition to the resources that are owned by the system: \"Retain\" (default) keeps the PVCs of the core pod (db and logs volumes), \"DeletePVCs\" deletes the PVCs of the core pod, \"DeleteAll\" also deletes all the NooBaa buckets of the system with their objects before deleting the PVCs, which deletes the data that the system stored in the target buckets of the backing stores (the target buckets themselves belong to the user and are not deleted).",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"proxy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Proxy (optional) configures an HTTP proxy for the outbound traffic of the system (to cloud backing stores). On OpenShift the fields that are not set are populated from the cluster-wide Proxy object.",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.ProxySpec"),
+						},
+					},
+					"caBundle": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CABundle (optional) is a config map with PEM encoded CA certificates that the server trusts in addition to the system trust store, for example to connect to an s3-compatible backing store with a certificate of an enterprise CA. All the keys of the config map are used.",
+							Ref:         ref("k8s.io/api/core/v1.LocalObjectReference"),
+						},
+					},
+					"tls": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TLS (optional) configures the certificates that the S3 and mgmt endpoints serve instead of the self-signed certificates that the server generates.",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.TLSSpec"),
+						},
+					},
+					"security": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Security (optional) configures the security of the system",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.SecuritySpec"),
+						},
+					},
+					"forceUpgrade": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ForceUpgrade (optional) allows changing the image to an older version (downgrade) and skips the health check of the running system before upgrading. Use with care - a downgrade might not be able to read the db of a newer version.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.DBBackupSpec", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.ProxySpec", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.SecuritySpec", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.ServicesSpec", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.TLSSpec", "k8s.io/api/core/v1.Affinity", "k8s.io/api/core/v1.LocalObjectReference", "k8s.io/api/core/v1.ResourceRequirements", "k8s.io/api/core/v1.Toleration"},
+	}
+}
+
+func schema_pkg_apis_noobaa_v1_NooBaaStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "NooBaaStatus defines the observed state of System",
+				Properties: map[string]spec.Schema{
+					"observedGeneration": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ObservedGeneration is the most recent generation observed for this noobaa system. It corresponds to the CR generation, which is updated on mutation by the API Server.",
+							Type:        []string{"integer"},
+							Format:      "int64",
+						},
+					},
+					"phase": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Phase is a simple, high-level summary of where the System is in its lifecycle",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"conditions": {
+						VendorExtensible: spec.VendorExtensible{
+							Extensions: spec.Extensions{
+								"x-kubernetes-patch-merge-key": "type",
+								"x-kubernetes-patch-strategy":  "merge",
+							},
+						},
+						SchemaProps: spec.SchemaProps{
+							Description: "Current service state of the noobaa system. Based on: https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle#pod-conditions",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.SystemCondition"),
+									},
+								},
+							},
+						},
+					},
+					"nextRetryTime": {
+						SchemaProps: spec.SchemaProps{
+							Description: "NextRetryTime is when the operator will retry the reconcile after an error, unset when the last reconcile succeeded.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"proxy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Proxy is the effective proxy configuration of the system, combined from the spec and the cluster-wide proxy on OpenShift.",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.ProxySpec"),
+						},
+					},
+					"actualImage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ActualImage is set to report which image the operator is using",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"upgradeHistory": {
+						SchemaProps: spec.SchemaProps{
+							Description: "UpgradeHistory records the image upgrades of the system, the last entry is the latest upgrade.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.UpgradeHistoryEntry"),
+									},
+								},
+							},
+						},
+					},
+					"kms": {
+						SchemaProps: spec.SchemaProps{
+							Description: "KMS records the root master key of the system once it exists in the kms provider.",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.KMSStatus"),
+						},
+					},
+					"accounts": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.AccountsStatus"),
+						},
+					},
+					"services": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.ServicesStatus"),
+						},
+					},
+					"readme": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Readme is a user readable string with explanations on the system",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"observedGeneration", "phase", "actualImage", "accounts", "services", "readme"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.AccountsStatus", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.KMSStatus", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.ProxySpec", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.ServicesStatus", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.SystemCondition", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1.UpgradeHistoryEntry", "k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+	}
+}
@@ -36,7 +36,8 @@ type BackingStoreList struct {
 // +k8s:openapi-gen=true
 type BackingStoreSpec struct {
 
-	// Type
+	// Type is the type of the backing store
+	// +kubebuilder:validation:Enum=aws-s3,google-cloud-storage,azure-blob,s3-compatible
 	Type StoreType `json:"type"`
 
 	BucketName string `json:"bucketName"`
@@ -47,6 +48,11 @@ type BackingStoreSpec struct {
 	// S3Options specifies client options for the backing store
 	// +optional
 	S3Options *S3Options `json:"s3Options,omitempty"`
+
+	// CABundle (optional) is a config map with PEM encoded CA certificates to trust when connecting to the backing store.
+	// Note that the certificates are added to the trust store of the server, so they are trusted for all connections.
+	// +optional
+	CABundle *corev1.LocalObjectReference `json:"caBundle,omitempty"`
 }
 
 // StoreType is the backing store type enum
@@ -79,6 +85,7 @@ type S3Options struct {
 	// +optional
 	S3ForcePathStyle bool `json:"s3ForcePathStyle,omitempty"`
 	// SignatureVersion specifies the client signature version to use when signing requests.
+	// +kubebuilder:validation:Enum=v4,v2
 	// +optional
 	SignatureVersion S3SignatureVersion `json:"signatureVersion,omitempty"`
 }
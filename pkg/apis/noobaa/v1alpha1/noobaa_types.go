@@ -77,8 +77,317 @@ type NooBaaSpec struct {
 	// buckets, objects meta-data and mapping file parts to storage locations.
 	// +optional
 	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// CoreResources (optional) overrides the default resource requirements for the server container
+	// +optional
+	CoreResources *corev1.ResourceRequirements `json:"coreResources,omitempty"`
+
+	// DBResources (optional) overrides the default resource requirements for the mongodb container
+	// +optional
+	DBResources *corev1.ResourceRequirements `json:"dbResources,omitempty"`
+
+	// DBVolumeResources (optional) overrides the default PVC resource requirements for the database volume.
+	// For an existing system the PVC storage request can only grow,
+	// and requires a StorageClass that allows volume expansion.
+	// +optional
+	DBVolumeResources *corev1.ResourceRequirements `json:"dbVolumeResources,omitempty"`
+
+	// Tolerations (optional) passed through to the pods of the system
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity (optional) passed through to the pods of the system
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// NodeSelector (optional) passed through to the pods of the system
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// PriorityClassName (optional) passed through to the pods of the system
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// Services (optional) configures the mgmt and s3 services of the system
+	// +optional
+	Services ServicesSpec `json:"services,omitempty"`
+
+	// DBType (optional) selects the database of the system.
+	// "mongodb" (default) runs a mongodb container in the core pod with a PVC for its data,
+	// "external" connects to an existing MongoDB server using the url from ExternalDBSecret.
+	// The DBType cannot be changed after the system is created.
+	// +optional
+	// +kubebuilder:validation:Enum=mongodb,external
+	DBType DBType `json:"dbType,omitempty"`
+
+	// ExternalDBSecret (optional) is required when DBType is "external".
+	// The secret should contain the MongoDB connection url in the "db_url" key,
+	// and can contain a TLS CA bundle in the "ca.crt" key which will be mounted
+	// to the core pod in /etc/mongodb-tls/ca.crt and added to the url as the tlsCAFile option
+	// (unless the url already sets tlsCAFile or sslCA).
+	// +optional
+	ExternalDBSecret *corev1.LocalObjectReference `json:"externalDBSecret,omitempty"`
+
+	// DBBackup (optional) schedules periodic backups of the system database
+	// using a CronJob that runs `noobaa system backup` from the operator image.
+	// Only supported with the embedded mongodb (DBType "mongodb").
+	// +optional
+	DBBackup *DBBackupSpec `json:"dbBackup,omitempty"`
+
+	// RecoverySecret (optional) is a secret with the "email" and "password" of an admin account of the system.
+	// It is used to recover the operator credentials if the operator secret was lost
+	// and the admin secret of the system cannot be used to authenticate.
+	// +optional
+	RecoverySecret *corev1.LocalObjectReference `json:"recoverySecret,omitempty"`
+
+	// CleanupPolicy (optional) selects what the operator deletes when the system is deleted,
+	// in addition to the resources that are owned by the system:
+	// "Retain" (default) keeps the PVCs of the core pod (db and logs volumes),
+	// "DeletePVCs" deletes the PVCs of the core pod,
+	// "DeleteAll" also deletes all the NooBaa buckets of the system with their objects before deleting the PVCs,
+	// which deletes the data that the system stored in the target buckets of the backing stores
+	// (the target buckets themselves belong to the user and are not deleted).
+	// +optional
+	// +kubebuilder:validation:Enum=Retain,DeletePVCs,DeleteAll
+	CleanupPolicy CleanupPolicy `json:"cleanupPolicy,omitempty"`
+
+	// Proxy (optional) configures an HTTP proxy for the outbound traffic of the system (to cloud backing stores).
+	// On OpenShift the fields that are not set are populated from the cluster-wide Proxy object.
+	// +optional
+	Proxy ProxySpec `json:"proxy,omitempty"`
+
+	// CABundle (optional) is a config map with PEM encoded CA certificates that the server trusts
+	// in addition to the system trust store, for example to connect to an s3-compatible backing store
+	// with a certificate of an enterprise CA. All the keys of the config map are used.
+	// +optional
+	CABundle *corev1.LocalObjectReference `json:"caBundle,omitempty"`
+
+	// TLS (optional) configures the certificates that the S3 and mgmt endpoints serve
+	// instead of the self-signed certificates that the server generates.
+	// +optional
+	TLS TLSSpec `json:"tls,omitempty"`
+
+	// Security (optional) configures the security of the system
+	// +optional
+	Security SecuritySpec `json:"security,omitempty"`
+
+	// ForceUpgrade (optional) allows changing the image to an older version (downgrade)
+	// and skips the health check of the running system before upgrading.
+	// Use with care - a downgrade might not be able to read the db of a newer version.
+	// +optional
+	ForceUpgrade bool `json:"forceUpgrade,omitempty"`
+}
+
+// ServicesSpec defines the configuration of the system services
+type ServicesSpec struct {
+
+	// Mgmt (optional) configures the mgmt service
+	// +optional
+	Mgmt ServiceSpec `json:"mgmt,omitempty"`
+
+	// S3 (optional) configures the s3 service
+	// +optional
+	S3 ServiceSpec `json:"s3,omitempty"`
+}
+
+// ServiceSpec defines the configuration of a service
+type ServiceSpec struct {
+
+	// Type (optional) of the service (default LoadBalancer).
+	// With ClusterIP the operator connects to the mgmt service address, so it should run in the cluster.
+	// +optional
+	// +kubebuilder:validation:Enum=ClusterIP,NodePort,LoadBalancer
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// Annotations (optional) are added to the service annotations,
+	// for example to request an internal load balancer from the cloud provider.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// LoadBalancerSourceRanges (optional) restricts the client IPs of a LoadBalancer service
+	// +optional
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+}
+
+// ProxySpec defines the HTTP proxy configuration of the system
+type ProxySpec struct {
+
+	// HTTPProxy (optional) is the proxy url for http requests, for example "http://proxy.example.com:3128"
+	// +optional
+	HTTPProxy string `json:"httpProxy,omitempty"`
+
+	// HTTPSProxy (optional) is the proxy url for https requests
+	// +optional
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+
+	// NoProxy (optional) is a comma separated list of hosts, domains and CIDRs that should not use the proxy.
+	// The cluster internal addresses are always added.
+	// +optional
+	NoProxy string `json:"noProxy,omitempty"`
+}
+
+// TLSSpec defines the certificates of the system endpoints
+type TLSSpec struct {
+
+	// S3 (optional) configures the certificate of the s3 endpoint
+	// +optional
+	S3 TLSCertSpec `json:"s3,omitempty"`
+
+	// Mgmt (optional) configures the certificate of the mgmt endpoint
+	// +optional
+	Mgmt TLSCertSpec `json:"mgmt,omitempty"`
+}
+
+// TLSCertSpec defines the certificate of an endpoint,
+// either from a user provided secret or requested from cert-manager
+type TLSCertSpec struct {
+
+	// SecretRef (optional) is a secret of type kubernetes.io/tls (keys "tls.crt" and "tls.key") in the system namespace
+	// +optional
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+
+	// CertManager (optional) requests the certificate from cert-manager with a Certificate
+	// that the operator creates, and the issued certificate is kept in the secret "<system-name>-<endpoint>-tls".
+	// +optional
+	CertManager *CertManagerSpec `json:"certManager,omitempty"`
+}
+
+// CertManagerSpec defines the cert-manager Certificate that the operator requests
+type CertManagerSpec struct {
+
+	// IssuerRef is the cert-manager issuer of the certificate
+	IssuerRef CertManagerIssuerRef `json:"issuerRef"`
+
+	// DNSNames (optional) are added to the DNS names of the certificate,
+	// in addition to the cluster DNS names of the service that the operator adds.
+	// +optional
+	DNSNames []string `json:"dnsNames,omitempty"`
+}
+
+// CertManagerIssuerRef references a cert-manager Issuer or ClusterIssuer
+type CertManagerIssuerRef struct {
+
+	// Name of the issuer
+	Name string `json:"name"`
+
+	// Kind (optional) of the issuer - "Issuer" (default) or "ClusterIssuer"
+	// +optional
+	// +kubebuilder:validation:Enum=Issuer,ClusterIssuer
+	Kind string `json:"kind,omitempty"`
+}
+
+// SecuritySpec defines the security configuration of the system
+type SecuritySpec struct {
+
+	// KMS (optional) keeps the root master key of the system in an external key management service,
+	// and the server is configured with a reference to the key instead of keeping it with the core.
+	// +optional
+	KMS *KMSSpec `json:"kms,omitempty"`
 }
 
+// KMSSpec defines the key management service of the root master key
+type KMSSpec struct {
+
+	// Provider is the type of the key management service - "kubernetes" or "vault"
+	// +kubebuilder:validation:Enum=kubernetes,vault
+	Provider KMSProvider `json:"provider"`
+
+	// KeyName (optional) is the name of the root master key in the provider (default "<system-name>-root-master-key").
+	// The operator generates a random key only on the first install of the system, when the provider does not have it.
+	// A key that is missing for an existing system rejects the system, since the data is encrypted with the original key.
+	// +optional
+	KeyName string `json:"keyName,omitempty"`
+
+	// Secret (optional) is the secret that keeps the root master key for the "kubernetes" provider
+	// (default "<system-name>-root-master-key"). The secret is not owned by the system,
+	// so it is not deleted with the system.
+	// +optional
+	Secret *corev1.LocalObjectReference `json:"secret,omitempty"`
+
+	// Vault configures the "vault" provider
+	// +optional
+	Vault *VaultSpec `json:"vault,omitempty"`
+}
+
+// VaultSpec defines the connection to a HashiCorp Vault KV secrets engine
+type VaultSpec struct {
+
+	// Address is the url of the vault server, for example "https://vault.vault.svc:8200"
+	Address string `json:"address"`
+
+	// Path is the path of the vault secret that keeps the root master key,
+	// where the first element is the mount of the KV secrets engine, for example "secret/noobaa"
+	Path string `json:"path"`
+
+	// KVVersion (optional) is the version of the KV secrets engine - 1 or 2 (default 2)
+	// +optional
+	// +kubebuilder:validation:Enum=1,2
+	KVVersion int32 `json:"kvVersion,omitempty"`
+
+	// TokenSecret is a secret with the vault "token" key,
+	// the token should be allowed to read (and create on first use) the secret at Path.
+	TokenSecret corev1.LocalObjectReference `json:"tokenSecret"`
+
+	// CASecret (optional) is a secret with a "ca.crt" key to verify the vault server certificate
+	// +optional
+	CASecret *corev1.LocalObjectReference `json:"caSecret,omitempty"`
+}
+
+// KMSProvider is a string enum type for the key management service providers
+type KMSProvider string
+
+// These are the valid kms providers:
+const (
+	// KMSProviderKubernetes keeps the root master key in a kubernetes secret
+	KMSProviderKubernetes KMSProvider = "kubernetes"
+
+	// KMSProviderVault keeps the root master key in a HashiCorp Vault KV secrets engine
+	KMSProviderVault KMSProvider = "vault"
+)
+
+// DBBackupSpec defines the scheduled backups of the system database
+type DBBackupSpec struct {
+
+	// Schedule is the cron schedule of the backups, for example "0 3 * * *" for a daily backup.
+	Schedule string `json:"schedule"`
+
+	// VolumeClaimName is the name of an existing PVC in the system namespace
+	// where the backup files are written.
+	VolumeClaimName string `json:"volumeClaimName"`
+
+	// MaxBackups (optional) is the number of backup files to keep in the volume,
+	// older backup files are deleted after every successful backup (default 7).
+	// +optional
+	MaxBackups int32 `json:"maxBackups,omitempty"`
+}
+
+// CleanupPolicy is a string enum type for the system cleanup policies
+type CleanupPolicy string
+
+// These are the valid cleanup policies:
+const (
+	// CleanupPolicyRetain keeps the PVCs of the system when it is deleted (default)
+	CleanupPolicyRetain CleanupPolicy = "Retain"
+
+	// CleanupPolicyDeletePVCs deletes the PVCs of the system when it is deleted
+	CleanupPolicyDeletePVCs CleanupPolicy = "DeletePVCs"
+
+	// CleanupPolicyDeleteAll deletes the NooBaa buckets with their objects and the PVCs of the system when it is deleted
+	CleanupPolicyDeleteAll CleanupPolicy = "DeleteAll"
+)
+
+// DBType is a string enum type for the system database types
+type DBType string
+
+// These are the valid db types:
+const (
+	// DBTypeMongoDB runs an embedded mongodb container in the core pod (default)
+	DBTypeMongoDB DBType = "mongodb"
+
+	// DBTypeExternal connects to an external MongoDB server
+	DBTypeExternal DBType = "external"
+)
+
 // NooBaaStatus defines the observed state of System
 // +k8s:openapi-gen=true
 type NooBaaStatus struct {
@@ -88,6 +397,7 @@ type NooBaaStatus struct {
 	ObservedGeneration int64 `json:"observedGeneration"`
 
 	// Phase is a simple, high-level summary of where the System is in its lifecycle
+	// +kubebuilder:validation:Enum=Rejected,Verifying,Creating,WaitingToConnect,Upgrading,Configuring,Ready,Deleting
 	Phase SystemPhase `json:"phase"`
 
 	// Current service state of the noobaa system.
@@ -97,9 +407,27 @@ type NooBaaStatus struct {
 	// +patchStrategy=merge
 	Conditions []SystemCondition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 
+	// NextRetryTime is when the operator will retry the reconcile after an error,
+	// unset when the last reconcile succeeded.
+	// +optional
+	NextRetryTime *metav1.Time `json:"nextRetryTime,omitempty"`
+
+	// Proxy is the effective proxy configuration of the system,
+	// combined from the spec and the cluster-wide proxy on OpenShift.
+	// +optional
+	Proxy *ProxySpec `json:"proxy,omitempty"`
+
 	// ActualImage is set to report which image the operator is using
 	ActualImage string `json:"actualImage"`
 
+	// UpgradeHistory records the image upgrades of the system, the last entry is the latest upgrade.
+	// +optional
+	UpgradeHistory []UpgradeHistoryEntry `json:"upgradeHistory,omitempty"`
+
+	// KMS records the root master key of the system once it exists in the kms provider.
+	// +optional
+	KMS *KMSStatus `json:"kms,omitempty"`
+
 	Accounts AccountsStatus `json:"accounts"`
 
 	Services ServicesStatus `json:"services"`
@@ -128,11 +456,74 @@ const (
 	// SystemPhaseWaitingToConnect means the operator is waiting to connect to the pods and services it created
 	SystemPhaseWaitingToConnect SystemPhase = "WaitingToConnect"
 
+	// SystemPhaseUpgrading means the operator is upgrading the system to a new image
+	SystemPhaseUpgrading SystemPhase = "Upgrading"
+
 	// SystemPhaseConfiguring means the operator is configuring the as requested
 	SystemPhaseConfiguring SystemPhase = "Configuring"
 
 	// SystemPhaseReady means the noobaa system has been created and ready to serve.
 	SystemPhaseReady SystemPhase = "Ready"
+
+	// SystemPhaseDeleting means the operator is running the cleanup policy of a deleted system
+	SystemPhaseDeleting SystemPhase = "Deleting"
+)
+
+// KMSStatus records where the root master key of the system is kept
+type KMSStatus struct {
+
+	// Provider is the kms provider that keeps the key
+	Provider KMSProvider `json:"provider"`
+
+	// KeyName is the name of the key in the provider
+	KeyName string `json:"keyName"`
+}
+
+// UpgradeHistoryEntry records a single upgrade of the system image
+type UpgradeHistoryEntry struct {
+
+	// FromImage is the image that was running before the upgrade
+	FromImage string `json:"fromImage"`
+
+	// ToImage is the image requested by the upgrade
+	ToImage string `json:"toImage"`
+
+	// State of the upgrade (Pending, Rolling, Completed, Rejected)
+	State UpgradeState `json:"state"`
+
+	// StartTime is the time the upgrade was first requested
+	StartTime metav1.Time `json:"startTime"`
+
+	// EndTime is the time the upgrade was completed or rejected
+	// +optional
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+
+	// DBBackup is the path of the db backup file that was taken before rolling the pods,
+	// the file is kept in the db volume of the core pod.
+	// +optional
+	DBBackup string `json:"dbBackup,omitempty"`
+
+	// Message is a human readable message with the last details of the upgrade
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// UpgradeState is a string enum type for upgrade states
+type UpgradeState string
+
+// These are the valid upgrade states:
+const (
+	// UpgradeStatePending means the upgrade is waiting for the checks and db backup before rolling the pods
+	UpgradeStatePending UpgradeState = "Pending"
+
+	// UpgradeStateRolling means the pods are being rolled to the new image
+	UpgradeStateRolling UpgradeState = "Rolling"
+
+	// UpgradeStateCompleted means the pods are running the new image and the system is connected
+	UpgradeStateCompleted UpgradeState = "Completed"
+
+	// UpgradeStateRejected means the upgrade was rejected and the system was left on the previous image
+	UpgradeStateRejected UpgradeState = "Rejected"
 )
 
 // SystemCondition contains details for the current condition of this system.
@@ -161,7 +552,23 @@ type ConditionType string
 
 // These are the valid conditions types and statuses:
 const (
+	// ConditionTypePhase has the phase as its status (kept for compatibility, use the standard conditions instead)
 	ConditionTypePhase ConditionType = "Phase"
+
+	// ConditionTypeAvailable is true when the system is running and the operator is connected to its server
+	ConditionTypeAvailable ConditionType = "Available"
+
+	// ConditionTypeProgressing is true while the operator is applying changes to the system
+	// (create, upgrade, rollout etc.) and waits for them to complete
+	ConditionTypeProgressing ConditionType = "Progressing"
+
+	// ConditionTypeDegraded is true when the operator cannot reconcile the system to its desired state
+	// without a change to the spec or to the environment (a persistent error)
+	ConditionTypeDegraded ConditionType = "Degraded"
+
+	// ConditionTypeCredentialsLost is true when the operator cannot authenticate to the system
+	// with the operator secret, the admin secret or the recovery secret.
+	ConditionTypeCredentialsLost ConditionType = "CredentialsLost"
 )
 
 // ConditionStatus is a simple string type.
@@ -192,6 +599,14 @@ type ServicesStatus struct {
 // UserStatus is the status info of a user secret
 type UserStatus struct {
 	SecretRef corev1.SecretReference `json:"secretRef"`
+
+	// PasswordRotationTime is the last time the password was rotated
+	// +optional
+	PasswordRotationTime *metav1.Time `json:"passwordRotationTime,omitempty"`
+
+	// KeysRotationTime is the last time the access keys were rotated
+	// +optional
+	KeysRotationTime *metav1.Time `json:"keysRotationTime,omitempty"`
 }
 
 // ServiceStatus is the status info and network addresses of a service
@@ -74,7 +74,7 @@ func schema_pkg_apis_noobaa_v1alpha1_BackingStoreSpec(ref common.ReferenceCallba
 				Properties: map[string]spec.Schema{
 					"type": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Type",
+							Description: "Type is the type of the backing store",
 							Type:        []string{"string"},
 							Format:      "",
 						},
@@ -97,12 +97,18 @@ func schema_pkg_apis_noobaa_v1alpha1_BackingStoreSpec(ref common.ReferenceCallba
 							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.S3Options"),
 						},
 					},
+					"caBundle": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CABundle (optional) is a config map with PEM encoded CA certificates to trust when connecting to the backing store. Note that the certificates are added to the trust store of the server, so they are trusted for all connections.",
+							Ref:         ref("k8s.io/api/core/v1.LocalObjectReference"),
+						},
+					},
 				},
 				Required: []string{"type", "bucketName", "secret"},
 			},
 		},
 		Dependencies: []string{
-			"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.S3Options", "k8s.io/api/core/v1.SecretReference"},
+			"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.S3Options", "k8s.io/api/core/v1.LocalObjectReference", "k8s.io/api/core/v1.SecretReference"},
 	}
 }
 
@@ -264,11 +270,138 @@ func schema_pkg_apis_noobaa_v1alpha1_NooBaaSpec(ref common.ReferenceCallback) co
 							Format:      "",
 						},
 					},
+					"coreResources": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CoreResources (optional) overrides the default resource requirements for the server container",
+							Ref:         ref("k8s.io/api/core/v1.ResourceRequirements"),
+						},
+					},
+					"dbResources": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DBResources (optional) overrides the default resource requirements for the mongodb container",
+							Ref:         ref("k8s.io/api/core/v1.ResourceRequirements"),
+						},
+					},
+					"dbVolumeResources": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DBVolumeResources (optional) overrides the default PVC resource requirements for the database volume. For an existing system the PVC storage request can only grow, and requires a StorageClass that allows volume expansion.",
+							Ref:         ref("k8s.io/api/core/v1.ResourceRequirements"),
+						},
+					},
+					"tolerations": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Tolerations (optional) passed through to the pods of the system",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("k8s.io/api/core/v1.Toleration"),
+									},
+								},
+							},
+						},
+					},
+					"affinity": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Affinity (optional) passed through to the pods of the system",
+							Ref:         ref("k8s.io/api/core/v1.Affinity"),
+						},
+					},
+					"nodeSelector": {
+						SchemaProps: spec.SchemaProps{
+							Description: "NodeSelector (optional) passed through to the pods of the system",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"priorityClassName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PriorityClassName (optional) passed through to the pods of the system",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"services": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Services (optional) configures the mgmt and s3 services of the system",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.ServicesSpec"),
+						},
+					},
+					"dbType": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DBType (optional) selects the database of the system. \"mongodb\" (default) runs a mongodb container in the core pod with a PVC for its data, \"external\" connects to an existing MongoDB server using the url from ExternalDBSecret. The DBType cannot be changed after the system is created.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"externalDBSecret": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ExternalDBSecret (optional) is required when DBType is \"external\". The secret should contain the MongoDB connection url in the \"db_url\" key, and can contain a TLS CA bundle in the \"ca.crt\" key which will be mounted to the core pod in /etc/mongodb-tls/ca.crt and added to the url as the tlsCAFile option (unless the url already sets tlsCAFile or sslCA).",
+							Ref:         ref("k8s.io/api/core/v1.LocalObjectReference"),
+						},
+					},
+					"dbBackup": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DBBackup (optional) schedules periodic backups of the system database using a CronJob that runs `noobaa system backup` from the operator image. Only supported with the embedded mongodb (DBType \"mongodb\").",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.DBBackupSpec"),
+						},
+					},
+					"recoverySecret": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RecoverySecret (optional) is a secret with the \"email\" and \"password\" of an admin account of the system. It is used to recover the operator credentials if the operator secret was lost and the admin secret of the system cannot be used to authenticate.",
+							Ref:         ref("k8s.io/api/core/v1.LocalObjectReference"),
+						},
+					},
+					"cleanupPolicy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CleanupPolicy (optional) selects what the operator deletes when the system is deleted, in addition to the resources that are owned by the system: \"Retain\" (default) keeps the PVCs of the core pod (db and logs volumes), \"DeletePVCs\" deletes the PVCs of the core pod, \"DeleteAll\" also deletes all the NooBaa buckets of the system with their objects before deleting the PVCs, which deletes the data that the system stored in the target buckets of the backing stores (the target buckets themselves belong to the user and are not deleted).",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"proxy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Proxy (optional) configures an HTTP proxy for the outbound traffic of the system (to cloud backing stores). On OpenShift the fields that are not set are populated from the cluster-wide Proxy object.",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.ProxySpec"),
+						},
+					},
+					"caBundle": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CABundle (optional) is a config map with PEM encoded CA certificates that the server trusts in addition to the system trust store, for example to connect to an s3-compatible backing store with a certificate of an enterprise CA. All the keys of the config map are used.",
+							Ref:         ref("k8s.io/api/core/v1.LocalObjectReference"),
+						},
+					},
+					"tls": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TLS (optional) configures the certificates that the S3 and mgmt endpoints serve instead of the self-signed certificates that the server generates.",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.TLSSpec"),
+						},
+					},
+					"security": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Security (optional) configures the security of the system",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.SecuritySpec"),
+						},
+					},
+					"forceUpgrade": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ForceUpgrade (optional) allows changing the image to an older version (downgrade) and skips the health check of the running system before upgrading. Use with care - a downgrade might not be able to read the db of a newer version.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
 				},
 			},
 		},
 		Dependencies: []string{
-			"k8s.io/api/core/v1.LocalObjectReference"},
+			"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.DBBackupSpec", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.ProxySpec", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.SecuritySpec", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.ServicesSpec", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.TLSSpec", "k8s.io/api/core/v1.Affinity", "k8s.io/api/core/v1.LocalObjectReference", "k8s.io/api/core/v1.ResourceRequirements", "k8s.io/api/core/v1.Toleration"},
 	}
 }
 
@@ -311,6 +444,18 @@ func schema_pkg_apis_noobaa_v1alpha1_NooBaaStatus(ref common.ReferenceCallback)
 							},
 						},
 					},
+					"nextRetryTime": {
+						SchemaProps: spec.SchemaProps{
+							Description: "NextRetryTime is when the operator will retry the reconcile after an error, unset when the last reconcile succeeded.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"proxy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Proxy is the effective proxy configuration of the system, combined from the spec and the cluster-wide proxy on OpenShift.",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.ProxySpec"),
+						},
+					},
 					"actualImage": {
 						SchemaProps: spec.SchemaProps{
 							Description: "ActualImage is set to report which image the operator is using",
@@ -318,6 +463,25 @@ func schema_pkg_apis_noobaa_v1alpha1_NooBaaStatus(ref common.ReferenceCallback)
 							Format:      "",
 						},
 					},
+					"upgradeHistory": {
+						SchemaProps: spec.SchemaProps{
+							Description: "UpgradeHistory records the image upgrades of the system, the last entry is the latest upgrade.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.UpgradeHistoryEntry"),
+									},
+								},
+							},
+						},
+					},
+					"kms": {
+						SchemaProps: spec.SchemaProps{
+							Description: "KMS records the root master key of the system once it exists in the kms provider.",
+							Ref:         ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.KMSStatus"),
+						},
+					},
 					"accounts": {
 						SchemaProps: spec.SchemaProps{
 							Ref: ref("github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.AccountsStatus"),
@@ -340,6 +504,6 @@ func schema_pkg_apis_noobaa_v1alpha1_NooBaaStatus(ref common.ReferenceCallback)
 			},
 		},
 		Dependencies: []string{
-			"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.AccountsStatus", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.ServicesStatus", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.SystemCondition"},
+			"github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.AccountsStatus", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.KMSStatus", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.ProxySpec", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.ServicesStatus", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.SystemCondition", "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1.UpgradeHistoryEntry", "k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
 	}
 }
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/noobaa/noobaa-operator/pkg/nb"
+	"github.com/noobaa/noobaa-operator/pkg/system"
+	"github.com/noobaa/noobaa-operator/pkg/util"
+)
+
+// AccountRotateKeys runs a CLI command
+func (cli *CLI) AccountRotateKeys(args []string) {
+	email := system.AdminAccountEmail
+	if len(args) > 0 && args[0] != "" {
+		email = args[0]
+	}
+
+	// the admin keys are kept in the admin secret so the operator rotates them
+	if email == system.AdminAccountEmail {
+		cli.SystemRequestRotation(system.AnnotationRotateAdminKeys)
+		return
+	}
+
+	nbClient := cli.GetNBClient()
+	_, err := nbClient.GenerateAccountKeysAPI(nb.GenerateAccountKeysParams{Email: email})
+	util.Panic(err)
+	account, err := nbClient.ReadAccountAPI(nb.ReadAccountParams{Email: email})
+	util.Panic(err)
+	if len(account.AccessKeys) == 0 {
+		cli.Log.Fatalf("❌ Account %s has no access keys after rotation", email)
+	}
+	cli.Log.Printf("✅ Rotated the access keys of account %s\n", email)
+	fmt.Printf("AWS_ACCESS_KEY_ID=%s\n", account.AccessKeys[0].AccessKey)
+	fmt.Printf("AWS_SECRET_ACCESS_KEY=%s\n", account.AccessKeys[0].SecretKey)
+}
@@ -0,0 +1,295 @@
+package cli
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+	"github.com/noobaa/noobaa-operator/pkg/system"
+	"github.com/noobaa/noobaa-operator/pkg/util"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// The backup file is a tar with the db archive (mongodump --archive --gzip)
+// and the system objects needed to bring the system back with the same credentials.
+const (
+	backupFileDB             = "db.archive.gz"
+	backupFileNooBaa         = "noobaa.yaml"
+	backupFileSecretServer   = "secret-server.yaml"
+	backupFileSecretOperator = "secret-operator.yaml"
+	backupFileSecretAdmin    = "secret-admin.yaml"
+)
+
+// SystemBackup runs a CLI command
+func (cli *CLI) SystemBackup() {
+
+	s := system.New(types.NamespacedName{Namespace: cli.Namespace, Name: cli.SystemName}, cli.Client, scheme.Scheme, nil)
+	if !util.KubeCheck(cli.Client, s.NooBaa) {
+		cli.Log.Fatalf("❌ System not found")
+	}
+	if s.NooBaa.Spec.DBType == nbv1.DBTypeExternal {
+		cli.Log.Fatalf("❌ Backup of an external db is not supported - use the backup tools of the db provider")
+	}
+	pod := cli.GetCorePod(s)
+	if !util.KubeCheck(cli.Client, s.SecretServer) ||
+		!util.KubeCheck(cli.Client, s.SecretOp) ||
+		!util.KubeCheck(cli.Client, s.SecretAdmin) {
+		cli.Log.Fatalf("❌ System secrets not found")
+	}
+
+	output := cli.BackupOutput
+	if output == "" {
+		output = "."
+	}
+	outputDir := output
+	if stat, err := os.Stat(output); err == nil && stat.IsDir() {
+		output = filepath.Join(output, fmt.Sprintf("noobaa-backup-%s-%s.tar",
+			cli.SystemName, time.Now().UTC().Format("20060102-150405")))
+	} else {
+		outputDir = filepath.Dir(output)
+	}
+
+	// dump the db to a temp file since the tar header needs the size in advance
+	dbFile, err := ioutil.TempFile(outputDir, ".noobaa-backup-db-")
+	util.Panic(err)
+	defer os.Remove(dbFile.Name())
+	defer dbFile.Close()
+
+	cli.Log.Printf("⏳ Dumping db from pod %s ...\n", pod.Name)
+	var stderr bytes.Buffer
+	err = util.KubeExec(pod, "mongodb",
+		[]string{system.MongoBinDir + "mongodump", "--quiet", "--archive", "--gzip", "--db", "nbcore"},
+		nil, dbFile, &stderr)
+	if err != nil {
+		cli.Log.Fatalf("❌ mongodump failed: %s %s", err, stderr.String())
+	}
+	dbSize, err := dbFile.Seek(0, io.SeekCurrent)
+	util.Panic(err)
+	_, err = dbFile.Seek(0, io.SeekStart)
+	util.Panic(err)
+
+	// write to a temp file and rename when done to avoid leaving partial backups
+	tmpFile, err := ioutil.TempFile(outputDir, ".noobaa-backup-")
+	util.Panic(err)
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+	util.Panic(tmpFile.Chmod(0600))
+
+	tw := tar.NewWriter(tmpFile)
+	writeEntry := func(name string, size int64, r io.Reader) {
+		util.Panic(tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    size,
+			ModTime: time.Now(),
+		}))
+		_, err := io.Copy(tw, r)
+		util.Panic(err)
+	}
+	writeObject := func(name string, obj interface{}) {
+		data, err := yaml.Marshal(obj)
+		util.Panic(err)
+		writeEntry(name, int64(len(data)), bytes.NewReader(data))
+	}
+
+	writeObject(backupFileNooBaa, &nbv1.NooBaa{
+		TypeMeta:   metav1.TypeMeta{APIVersion: nbv1.SchemeGroupVersion.String(), Kind: "NooBaa"},
+		ObjectMeta: metav1.ObjectMeta{Name: s.NooBaa.Name, Labels: s.NooBaa.Labels},
+		Spec:       s.NooBaa.Spec,
+	})
+	writeObject(backupFileSecretServer, backupSecret(s.SecretServer))
+	writeObject(backupFileSecretOperator, backupSecret(s.SecretOp))
+	writeObject(backupFileSecretAdmin, backupSecret(s.SecretAdmin))
+	writeEntry(backupFileDB, dbSize, dbFile)
+	util.Panic(tw.Close())
+	util.Panic(tmpFile.Close())
+	util.Panic(os.Rename(tmpFile.Name(), output))
+
+	cli.Log.Printf("✅ Backup written to %s (contains credentials - keep it safe)\n", output)
+
+	if cli.BackupMaxBackups > 0 && outputDir != "" {
+		cli.pruneBackups(outputDir)
+	}
+}
+
+// SystemRestore runs a CLI command
+func (cli *CLI) SystemRestore() {
+
+	if cli.RestoreInput == "" {
+		cli.Log.Fatalf("❌ Missing backup file to restore (--input)")
+	}
+	input, err := os.Open(cli.RestoreInput)
+	util.Panic(err)
+	defer input.Close()
+
+	// read the backup objects, and extract the db archive to a temp file
+	sys := &nbv1.NooBaa{}
+	secrets := map[string]*corev1.Secret{}
+	dbFile, err := ioutil.TempFile("", ".noobaa-restore-db-")
+	util.Panic(err)
+	defer os.Remove(dbFile.Name())
+	defer dbFile.Close()
+	hasDB := false
+
+	tr := tar.NewReader(input)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		util.Panic(err)
+		switch header.Name {
+		case backupFileDB:
+			_, err = io.Copy(dbFile, tr)
+			util.Panic(err)
+			hasDB = true
+		case backupFileNooBaa:
+			data, err := ioutil.ReadAll(tr)
+			util.Panic(err)
+			util.Panic(yaml.Unmarshal(data, sys))
+		case backupFileSecretServer, backupFileSecretOperator, backupFileSecretAdmin:
+			data, err := ioutil.ReadAll(tr)
+			util.Panic(err)
+			secret := &corev1.Secret{}
+			util.Panic(yaml.Unmarshal(data, secret))
+			secrets[header.Name] = secret
+		}
+	}
+	if !hasDB || sys.Name == "" || len(secrets) != 3 {
+		cli.Log.Fatalf("❌ Invalid backup file %s", cli.RestoreInput)
+	}
+	_, err = dbFile.Seek(0, io.SeekStart)
+	util.Panic(err)
+
+	// the system name is stored in the db so we restore with the same name
+	if sys.Name != cli.SystemName {
+		cli.Log.Printf("Restoring system name \"%s\" from the backup\n", sys.Name)
+		cli.SystemName = sys.Name
+	}
+	s := system.New(types.NamespacedName{Namespace: cli.Namespace, Name: cli.SystemName}, cli.Client, scheme.Scheme, nil)
+	if util.KubeCheck(cli.Client, s.NooBaa) {
+		cli.Log.Fatalf("❌ Restore requires a fresh system - delete the existing system first (noobaa system delete)")
+	}
+
+	// create the secrets before the system, so the operator will use the restored credentials
+	// instead of creating a new system in the server.
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: cli.Namespace}}
+	util.KubeCreateSkipExisting(cli.Client, ns)
+	for _, secret := range secrets {
+		secret.Namespace = cli.Namespace
+		util.KubeCreateSkipExisting(cli.Client, secret)
+	}
+	// the system is created with the restoring annotation so that the operator holds the server
+	// from starting until the db is restored, otherwise it would initialize a new system in the empty db.
+	sys.Namespace = cli.Namespace
+	if sys.Annotations == nil {
+		sys.Annotations = map[string]string{}
+	}
+	sys.Annotations[system.AnnotationRestoringDB] = "true"
+	util.KubeCreateSkipExisting(cli.Client, sys)
+	for _, secret := range secrets {
+		util.Panic(controllerutil.SetControllerReference(sys, secret, scheme.Scheme))
+		util.Panic(cli.Client.Update(cli.Ctx, secret))
+	}
+
+	pod := cli.WaitCorePodDB(s)
+	cli.Log.Printf("⏳ Restoring db to pod %s ...\n", pod.Name)
+	var stderr bytes.Buffer
+	err = util.KubeExec(pod, "mongodb",
+		[]string{system.MongoBinDir + "mongorestore", "--quiet", "--archive", "--gzip", "--drop"},
+		dbFile, nil, &stderr)
+	if err != nil {
+		cli.Log.Fatalf("❌ mongorestore failed: %s %s", err, stderr.String())
+	}
+
+	// removing the annotation makes the operator roll the core pod to start the server on the restored db
+	cli.Log.Printf("✅ Restored db. Starting the server ...\n")
+	util.Panic(cli.Client.Get(cli.Ctx, client.ObjectKey{Namespace: sys.Namespace, Name: sys.Name}, sys))
+	delete(sys.Annotations, system.AnnotationRestoringDB)
+	util.Panic(cli.Client.Update(cli.Ctx, sys))
+	cli.SystemWaitReady()
+}
+
+// GetCorePod returns the core pod of the system and fails if it is not running
+func (cli *CLI) GetCorePod(s *system.System) *corev1.Pod {
+	pod := &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: s.CoreApp.Name + "-0", Namespace: cli.Namespace},
+	}
+	if !util.KubeCheck(cli.Client, pod) || pod.Status.Phase != corev1.PodRunning {
+		cli.Log.Fatalf("❌ Core pod %s is not running", pod.Name)
+	}
+	return pod
+}
+
+// WaitCorePodDB waits until the mongodb in the core pod accepts connections
+func (cli *CLI) WaitCorePodDB(s *system.System) *corev1.Pod {
+	pod := &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: s.CoreApp.Name + "-0", Namespace: cli.Namespace},
+	}
+	intervalSec := time.Duration(3)
+	util.Panic(wait.PollImmediateInfinite(intervalSec*time.Second, func() (bool, error) {
+		err := cli.Client.Get(cli.Ctx, client.ObjectKey{Namespace: pod.Namespace, Name: pod.Name}, pod)
+		if err != nil || pod.Status.Phase != corev1.PodRunning {
+			cli.Log.Printf("⏳ Waiting for core pod %s to run ...\n", pod.Name)
+			return false, nil
+		}
+		var stdout bytes.Buffer
+		err = util.KubeExec(pod, "mongodb",
+			[]string{system.MongoBinDir + "mongo", "--quiet", "--eval", "db.runCommand({ping:1}).ok"},
+			nil, &stdout, nil)
+		if err != nil || strings.TrimSpace(stdout.String()) != "1" {
+			cli.Log.Printf("⏳ Waiting for mongodb in core pod %s ...\n", pod.Name)
+			return false, nil
+		}
+		return true, nil
+	}))
+	return pod
+}
+
+// pruneBackups deletes the oldest backup files of the system in the dir to keep BackupMaxBackups
+func (cli *CLI) pruneBackups(dir string) {
+	files, err := filepath.Glob(filepath.Join(dir, "noobaa-backup-"+cli.SystemName+"-*.tar"))
+	util.Panic(err)
+	// the timestamp in the name sorts by time
+	sort.Strings(files)
+	for len(files) > cli.BackupMaxBackups {
+		cli.Log.Printf("Deleting old backup %s\n", files[0])
+		util.Panic(os.Remove(files[0]))
+		files = files[1:]
+	}
+}
+
+// backupSecret returns a copy of the secret without the cluster specific metadata
+func backupSecret(secret *corev1.Secret) *corev1.Secret {
+	data := map[string][]byte{}
+	for key, val := range secret.StringData {
+		data[key] = []byte(val)
+	}
+	for key, val := range secret.Data {
+		data[key] = val
+	}
+	return &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Name: secret.Name, Labels: secret.Labels},
+		Type:       secret.Type,
+		Data:       data,
+	}
+}
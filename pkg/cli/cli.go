@@ -59,6 +59,12 @@ type CLI struct {
 	NooBaaImage      string
 	OperatorImage    string
 	ImagePullSecret  string
+	ExternalDBSecret string
+	CleanupPolicy    string
+
+	BackupOutput     string
+	BackupMaxBackups int
+	RestoreInput     string
 
 	// Commands
 	Cmd          *cobra.Command
@@ -67,6 +73,7 @@ type CLI struct {
 	CmdInstall   *cobra.Command
 	CmdUninstall *cobra.Command
 	CmdStatus    *cobra.Command
+	CmdAccount   *cobra.Command
 	CmdBucket    *cobra.Command
 	CmdCrd       *cobra.Command
 	CmdOlmHub    *cobra.Command
@@ -98,6 +105,8 @@ func New() *CLI {
 		NooBaaImage:      system.ContainerImage,
 		OperatorImage:    "noobaa/noobaa-operator:" + version.Version,
 		ImagePullSecret:  "",
+		ExternalDBSecret: "",
+		CleanupPolicy:    "",
 
 		// Root command
 		Cmd: &cobra.Command{
@@ -120,6 +129,10 @@ func New() *CLI {
 		},
 
 		// Manage Commands:
+		CmdAccount: &cobra.Command{
+			Use:   "account",
+			Short: "Manage noobaa accounts",
+		},
 		CmdBucket: &cobra.Command{
 			Use:   "bucket",
 			Short: "Manage noobaa buckets",
@@ -167,6 +180,13 @@ func New() *CLI {
 		cli.CmdOptions.Usage()
 	})
 
+	cli.CmdAccount.AddCommand(
+		&cobra.Command{
+			Use:   "rotate-keys [email]",
+			Short: "Rotate the S3 access keys of an account (default admin, updates the admin secret)",
+			Run:   ToRunnableArgs(cli.AccountRotateKeys),
+		},
+	)
 	cli.CmdBucket.AddCommand(
 		&cobra.Command{
 			Use:   "create",
@@ -201,6 +221,11 @@ func New() *CLI {
 			Short: "Status of noobaa CRDs",
 			Run:   ToRunnable(cli.CrdsStatus),
 		},
+		&cobra.Command{
+			Use:   "migrate",
+			Short: "Migrate stored noobaa objects to the CRDs storage version",
+			Run:   ToRunnable(cli.CrdsMigrate),
+		},
 		&cobra.Command{
 			Use:   "yaml",
 			Short: "Show bundled CRDs",
@@ -282,6 +307,29 @@ func New() *CLI {
 		},
 	)
 
+	cmdSystemBackup := &cobra.Command{
+		Use:   "backup",
+		Short: "Backup the system db and credentials to a file",
+		Run:   ToRunnable(cli.SystemBackup),
+	}
+	cmdSystemBackup.Flags().StringVar(
+		&cli.BackupOutput, "output",
+		cli.BackupOutput, "Backup file path, or a directory to create a timestamped backup file in",
+	)
+	cmdSystemBackup.Flags().IntVar(
+		&cli.BackupMaxBackups, "max-backups",
+		cli.BackupMaxBackups, "Number of backup files of the system to keep in the output directory (0 keeps all)",
+	)
+	cmdSystemRestore := &cobra.Command{
+		Use:   "restore",
+		Short: "Restore a backup file into a fresh system",
+		Run:   ToRunnable(cli.SystemRestore),
+	}
+	cmdSystemRestore.Flags().StringVar(
+		&cli.RestoreInput, "input",
+		cli.RestoreInput, "Backup file path",
+	)
+
 	cli.CmdSystem.AddCommand(
 		&cobra.Command{
 			Use:   "create",
@@ -308,6 +356,13 @@ func New() *CLI {
 			Short: "Show bundled noobaa yaml",
 			Run:   ToRunnable(cli.SystemYaml),
 		},
+		&cobra.Command{
+			Use:   "rotate-admin-password",
+			Short: "Rotate the admin password (updates the admin and operator secrets)",
+			Run:   ToRunnable(cli.SystemRotateAdminPassword),
+		},
+		cmdSystemBackup,
+		cmdSystemRestore,
 	)
 
 	flagset := cli.Cmd.PersistentFlags()
@@ -337,6 +392,14 @@ func New() *CLI {
 		&cli.ImagePullSecret, "image-pull-secret",
 		cli.ImagePullSecret, "Image pull secret (must be in same namespace)",
 	)
+	flagset.StringVar(
+		&cli.ExternalDBSecret, "external-db-secret",
+		cli.ExternalDBSecret, "Secret with the url of an external MongoDB (db_url key) to use instead of the embedded mongodb",
+	)
+	flagset.StringVar(
+		&cli.CleanupPolicy, "cleanup-policy",
+		cli.CleanupPolicy, "What to delete when the system is deleted - Retain (default), DeletePVCs or DeleteAll (also buckets data)",
+	)
 
 	groups := templates.CommandGroups{
 		{
@@ -350,6 +413,7 @@ func New() *CLI {
 		{
 			Message: "Manage:",
 			Commands: []*cobra.Command{
+				cli.CmdAccount,
 				cli.CmdBucket,
 			},
 		},
@@ -381,6 +445,15 @@ func (cli *CLI) Run() {
 	util.Panic(apis.AddToScheme(scheme.Scheme))
 	cmd, args, err := cli.Cmd.Traverse(os.Args[1:])
 	util.Panic(err)
+	// Traverse only parses the flags that appear before the last command.
+	// The help flag is added (like Execute does) so that --help is parsed and prints the help.
+	cmd.InitDefaultHelpFlag()
+	util.Panic(cmd.ParseFlags(args))
+	if help, _ := cmd.Flags().GetBool("help"); help {
+		util.Panic(cmd.Help())
+		return
+	}
+	args = cmd.Flags().Args()
 	if cmd.Runnable() &&
 		cmd != cli.CmdVersion &&
 		cmd != cli.CmdOptions {
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"os"
+	"testing"
+)
+
+// TestRunHelp checks that the help flag prints the help of the command instead of running it
+func TestRunHelp(t *testing.T) {
+	osArgs := os.Args
+	defer func() { os.Args = osArgs }()
+
+	for _, args := range [][]string{
+		{"--help"},
+		{"system", "--help"},
+		{"system", "backup", "--help"},
+		{"system", "restore", "-h"},
+	} {
+		os.Args = append([]string{"noobaa"}, args...)
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("noobaa %v panicked: %v", args, r)
+				}
+			}()
+			New().Run()
+		}()
+	}
+}
@@ -3,36 +3,123 @@ package cli
 import (
 	"fmt"
 	"os"
+	"reflect"
 	"time"
 
 	"github.com/noobaa/noobaa-operator/build/_output/bundle"
 	"github.com/noobaa/noobaa-operator/pkg/util"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/client-go/discovery"
 )
 
-// Crds is the
+const (
+	// CrdsAPIVersionV1 is the apiextensions version of the bundled CRDs (served since kubernetes 1.16)
+	CrdsAPIVersionV1 = "apiextensions.k8s.io/v1"
+
+	// CrdsAPIVersionV1beta1 is the apiextensions version for older clusters (removed in kubernetes 1.22)
+	CrdsAPIVersionV1beta1 = "apiextensions.k8s.io/v1beta1"
+)
+
+// Crds is the set of CRDs of the operator.
+// The CRDs are unstructured since they are loaded in the apiextensions version that the cluster serves.
 type Crds struct {
-	NooBaa            *apiextv1beta1.CustomResourceDefinition
-	BackingStore      *apiextv1beta1.CustomResourceDefinition
-	BucketClass       *apiextv1beta1.CustomResourceDefinition
-	ObjectBucket      *apiextv1beta1.CustomResourceDefinition
-	ObjectBucketClaim *apiextv1beta1.CustomResourceDefinition
+	NooBaa            *unstructured.Unstructured
+	BackingStore      *unstructured.Unstructured
+	BucketClass       *unstructured.Unstructured
+	ObjectBucket      *unstructured.Unstructured
+	ObjectBucketClaim *unstructured.Unstructured
 }
 
 // CrdsCreate runs a CLI command
 func (cli *CLI) CrdsCreate() {
 	crds := cli.LoadCrds()
-	util.KubeCreateSkipExisting(cli.Client, crds.NooBaa)
-	util.KubeCreateSkipExisting(cli.Client, crds.BackingStore)
-	util.KubeCreateSkipExisting(cli.Client, crds.BucketClass)
+	cli.CrdCreateOrUpdate(crds.NooBaa)
+	cli.CrdCreateOrUpdate(crds.BackingStore)
+	cli.CrdCreateOrUpdate(crds.BucketClass)
 	util.KubeCreateSkipExisting(cli.Client, crds.ObjectBucket)
 	util.KubeCreateSkipExisting(cli.Client, crds.ObjectBucketClaim)
 }
 
+// CrdCreateOrUpdate creates the CRD, or updates an existing CRD to the bundled one,
+// which adds the new API versions of the CRD on upgrades.
+// The CA bundle of the conversion webhook is kept since it is set by the operator.
+func (cli *CLI) CrdCreateOrUpdate(crd *unstructured.Unstructured) {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(crd.GroupVersionKind())
+	err := cli.Client.Get(cli.Ctx, client.ObjectKey{Name: crd.GetName()}, existing)
+	if errors.IsNotFound(err) {
+		util.KubeCreateSkipExisting(cli.Client, crd)
+		return
+	}
+	util.Panic(err)
+	crd.SetResourceVersion(existing.GetResourceVersion())
+	caBundle, found, _ := unstructured.NestedString(existing.Object, "spec", "conversion", "webhook", "clientConfig", "caBundle")
+	if _, isWebhook, _ := unstructured.NestedMap(crd.Object, "spec", "conversion", "webhook"); found && isWebhook {
+		util.Panic(unstructured.SetNestedField(crd.Object, caBundle, "spec", "conversion", "webhook", "clientConfig", "caBundle"))
+	}
+	util.KubeApply(cli.Client, crd)
+}
+
+// CrdsMigrate runs a CLI command.
+// It migrates the stored objects of the noobaa.io CRDs to the storage version by rewriting every object,
+// and then removes the older versions from the stored versions in the CRD status,
+// which allows to stop serving the older versions in the future.
+// It requires the conversion webhook of the operator when objects are stored in older versions.
+func (cli *CLI) CrdsMigrate() {
+	crds := cli.LoadCrds()
+	cli.CrdMigrate(crds.NooBaa)
+	cli.CrdMigrate(crds.BackingStore)
+	cli.CrdMigrate(crds.BucketClass)
+}
+
+// CrdMigrate migrates the objects of the CRD in all namespaces to the storage version
+func (cli *CLI) CrdMigrate(crd *unstructured.Unstructured) {
+	storageVersion := crdStorageVersion(crd)
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	listKind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "listKind")
+
+	list := &unstructured.UnstructuredList{}
+	list.SetAPIVersion(group + "/" + storageVersion)
+	list.SetKind(listKind)
+	util.Panic(cli.Client.List(cli.Ctx, &client.ListOptions{}, list))
+	for i := range list.Items {
+		obj := &list.Items[i]
+		// an update without changes writes the object in the storage version,
+		// and the api server skips the write when the object is already stored in that version.
+		// a concurrent change or delete of the object is fine since the change is written in the storage version too.
+		err := cli.Client.Update(cli.Ctx, obj)
+		if errors.IsConflict(err) || errors.IsNotFound(err) {
+			cli.Log.Printf("Skipped migrating %s %s/%s: %s", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+			continue
+		}
+		util.Panic(err)
+	}
+
+	util.Panic(cli.Client.Get(cli.Ctx, client.ObjectKey{Name: crd.GetName()}, crd))
+	util.Panic(unstructured.SetNestedStringSlice(crd.Object, []string{storageVersion}, "status", "storedVersions"))
+	util.Panic(cli.Client.Status().Update(cli.Ctx, crd))
+	cli.Log.Printf("✅ Migrated: CustomResourceDefinition %q to %s (%d objects)", crd.GetName(), storageVersion, len(list.Items))
+}
+
+// crdStorageVersion returns the name of the version that is stored by the CRD
+func crdStorageVersion(crd *unstructured.Unstructured) string {
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range versions {
+		version := v.(map[string]interface{})
+		if version["storage"] == true {
+			return version["name"].(string)
+		}
+	}
+	storageVersion, _, _ := unstructured.NestedString(crd.Object, "spec", "version")
+	return storageVersion
+}
+
 // CrdsDelete runs a CLI command
 func (cli *CLI) CrdsDelete() {
 	crds := cli.LoadCrds()
@@ -68,27 +155,99 @@ func (cli *CLI) CrdsYaml() {
 	p.PrintObj(crds.ObjectBucketClaim, os.Stdout)
 }
 
-// LoadCrds loads the CRDs structures from the bundled yamls
+// LoadCrds loads the CRDs structures from the bundled yamls,
+// in the apiextensions version that the cluster serves (see CrdsAPIVersion).
+// The conversion webhook of the CRDs is served by the operator in the CLI namespace.
 func (cli *CLI) LoadCrds() *Crds {
+	apiVersion := cli.CrdsAPIVersion()
 	crds := &Crds{}
-	o := util.KubeObject(bundle.File_deploy_crds_noobaa_v1alpha1_noobaa_crd_yaml)
-	crds.NooBaa = o.(*apiextv1beta1.CustomResourceDefinition)
-	o = util.KubeObject(bundle.File_deploy_crds_noobaa_v1alpha1_backingstore_crd_yaml)
-	crds.BackingStore = o.(*apiextv1beta1.CustomResourceDefinition)
-	o = util.KubeObject(bundle.File_deploy_crds_noobaa_v1alpha1_bucketclass_crd_yaml)
-	crds.BucketClass = o.(*apiextv1beta1.CustomResourceDefinition)
-	o = util.KubeObject(bundle.File_deploy_manual_crds_ob_v1alpha1_crd_yaml)
-	crds.ObjectBucket = o.(*apiextv1beta1.CustomResourceDefinition)
-	o = util.KubeObject(bundle.File_deploy_manual_crds_obc_v1alpha1_crd_yaml)
-	crds.ObjectBucketClaim = o.(*apiextv1beta1.CustomResourceDefinition)
+	crds.NooBaa = loadCrd(bundle.File_deploy_crds_noobaa_v1_noobaa_crd_yaml, apiVersion, cli.Namespace)
+	crds.BackingStore = loadCrd(bundle.File_deploy_crds_noobaa_v1_backingstore_crd_yaml, apiVersion, cli.Namespace)
+	crds.BucketClass = loadCrd(bundle.File_deploy_crds_noobaa_v1_bucketclass_crd_yaml, apiVersion, cli.Namespace)
+	crds.ObjectBucket = loadCrd(bundle.File_deploy_manual_crds_ob_v1alpha1_crd_yaml, apiVersion, cli.Namespace)
+	crds.ObjectBucketClaim = loadCrd(bundle.File_deploy_manual_crds_obc_v1alpha1_crd_yaml, apiVersion, cli.Namespace)
 	return crds
 }
 
+// CrdsAPIVersion returns the apiextensions version to use for the CRDs,
+// which is v1 when the cluster serves it, and v1beta1 for older clusters.
+func (cli *CLI) CrdsAPIVersion() string {
+	dc, err := discovery.NewDiscoveryClientForConfig(util.KubeConfig())
+	util.Panic(err)
+	_, err = dc.ServerResourcesForGroupVersion(CrdsAPIVersionV1)
+	if err == nil {
+		return CrdsAPIVersionV1
+	}
+	if errors.IsNotFound(err) {
+		return CrdsAPIVersionV1beta1
+	}
+	cli.Log.Warnf("Failed to discover the apiextensions version (using %s): %s", CrdsAPIVersionV1, err)
+	return CrdsAPIVersionV1
+}
+
+// loadCrd loads a bundled CRD yaml (apiextensions v1) and converts it to the requested apiextensions version.
+// The namespace is set as the namespace of the conversion webhook service.
+func loadCrd(text string, apiVersion string, namespace string) *unstructured.Unstructured {
+	crd := &unstructured.Unstructured{}
+	util.Panic(yaml.Unmarshal([]byte(text), &crd.Object))
+	if _, found, _ := unstructured.NestedMap(crd.Object, "spec", "conversion", "webhook", "clientConfig", "service"); found {
+		util.Panic(unstructured.SetNestedField(crd.Object, namespace, "spec", "conversion", "webhook", "clientConfig", "service", "namespace"))
+	}
+	if apiVersion == CrdsAPIVersionV1beta1 {
+		crdToV1beta1(crd)
+	}
+	return crd
+}
+
+// crdToV1beta1 converts a v1 CRD to v1beta1 for older clusters.
+// The schema, subresources and printer columns are set on the top level when they are the same for all versions,
+// since older clusters ignore the per-version fields unless the CustomResourceWebhookConversion feature is enabled.
+// The structural schema extensions (x-kubernetes-*) are kept, and are ignored by clusters that do not support them.
+// The conversion is removed (strategy None) since webhook conversion is not enabled by default on older clusters,
+// and the versions of our CRDs have the same schema so the api server converts them by setting the apiVersion.
+func crdToV1beta1(crd *unstructured.Unstructured) {
+	crd.SetAPIVersion(CrdsAPIVersionV1beta1)
+	unstructured.RemoveNestedField(crd.Object, "spec", "conversion")
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range versions {
+		columns, _ := v.(map[string]interface{})["additionalPrinterColumns"].([]interface{})
+		for _, c := range columns {
+			column := c.(map[string]interface{})
+			column["JSONPath"] = column["jsonPath"]
+			delete(column, "jsonPath")
+		}
+	}
+	for _, field := range []string{"schema", "subresources", "additionalPrinterColumns"} {
+		var common interface{}
+		same := true
+		for i, v := range versions {
+			value := v.(map[string]interface{})[field]
+			if i == 0 {
+				common = value
+			} else if !reflect.DeepEqual(common, value) {
+				same = false
+			}
+		}
+		if !same || common == nil {
+			continue
+		}
+		for _, v := range versions {
+			delete(v.(map[string]interface{}), field)
+		}
+		topField := field
+		if field == "schema" {
+			topField = "validation"
+		}
+		util.Panic(unstructured.SetNestedField(crd.Object, common, "spec", topField))
+	}
+	util.Panic(unstructured.SetNestedSlice(crd.Object, versions, "spec", "versions"))
+}
+
 // CrdsWaitReady waits for all CRDs to be ready
 func (cli *CLI) CrdsWaitReady() {
 	crds := cli.LoadCrds()
 	intervalSec := time.Duration(3)
-	list := []*apiextv1beta1.CustomResourceDefinition{
+	list := []*unstructured.Unstructured{
 		crds.NooBaa, crds.BackingStore, crds.BucketClass,
 	}
 	util.Panic(wait.PollImmediateInfinite(intervalSec*time.Second, func() (bool, error) {
@@ -106,20 +265,22 @@ func (cli *CLI) CrdsWaitReady() {
 }
 
 // CrdIsReady checks the status of a CRD
-func (cli *CLI) CrdIsReady(crd *apiextv1beta1.CustomResourceDefinition) (bool, error) {
-	err := cli.Client.Get(cli.Ctx, client.ObjectKey{Name: crd.Name}, crd)
+func (cli *CLI) CrdIsReady(crd *unstructured.Unstructured) (bool, error) {
+	err := cli.Client.Get(cli.Ctx, client.ObjectKey{Name: crd.GetName()}, crd)
 	if err != nil {
 		return false, err
 	}
-	for _, cond := range crd.Status.Conditions {
-		switch cond.Type {
-		case apiextv1beta1.Established:
-			if cond.Status == apiextv1beta1.ConditionTrue {
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, _ := c.(map[string]interface{})
+		switch cond["type"] {
+		case "Established":
+			if cond["status"] == "True" {
 				return true, nil
 			}
-		case apiextv1beta1.NamesAccepted:
-			if cond.Status == apiextv1beta1.ConditionFalse {
-				return false, fmt.Errorf("Name conflict: %v", cond.Reason)
+		case "NamesAccepted":
+			if cond["status"] == "False" {
+				return false, fmt.Errorf("Name conflict: %v", cond["reason"])
 			}
 		}
 	}
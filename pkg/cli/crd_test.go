@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/noobaa/noobaa-operator/build/_output/bundle"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const testNamespace = "test-noobaa"
+
+// testCrds are the bundled CRDs of the noobaa.io types which have multiple versions
+var testCrds = map[string]string{
+	"noobaas.noobaa.io":       bundle.File_deploy_crds_noobaa_v1_noobaa_crd_yaml,
+	"backingstores.noobaa.io": bundle.File_deploy_crds_noobaa_v1_backingstore_crd_yaml,
+	"bucketclasses.noobaa.io": bundle.File_deploy_crds_noobaa_v1_bucketclass_crd_yaml,
+}
+
+func TestLoadCrdV1(t *testing.T) {
+	for name, text := range testCrds {
+		crd := loadCrd(text, CrdsAPIVersionV1, testNamespace)
+		if crd.GetAPIVersion() != CrdsAPIVersionV1 || crd.GetName() != name {
+			t.Errorf("expected %s %s, got %s %s", CrdsAPIVersionV1, name, crd.GetAPIVersion(), crd.GetName())
+		}
+		strategy, _, _ := unstructured.NestedString(crd.Object, "spec", "conversion", "strategy")
+		if strategy != "Webhook" {
+			t.Errorf("%s: expected conversion strategy Webhook, got %q", name, strategy)
+		}
+		service, _, _ := unstructured.NestedStringMap(crd.Object, "spec", "conversion", "webhook", "clientConfig", "service")
+		if service["name"] != "noobaa-webhook" || service["namespace"] != testNamespace || service["path"] != "/convert" {
+			t.Errorf("%s: expected the conversion webhook service in the CLI namespace, got %v", name, service)
+		}
+		if storageVersion := crdStorageVersion(crd); storageVersion != "v1" {
+			t.Errorf("%s: expected storage version v1, got %q", name, storageVersion)
+		}
+		versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+		for _, v := range versions {
+			version := v.(map[string]interface{})
+			if version["served"] != true || version["schema"] == nil {
+				t.Errorf("%s: expected version %s to be served with a schema", name, version["name"])
+			}
+		}
+	}
+}
+
+func TestCrdToV1beta1(t *testing.T) {
+	for name, text := range testCrds {
+		crd := loadCrd(text, CrdsAPIVersionV1beta1, testNamespace)
+		if crd.GetAPIVersion() != CrdsAPIVersionV1beta1 {
+			t.Errorf("%s: expected %s, got %s", name, CrdsAPIVersionV1beta1, crd.GetAPIVersion())
+		}
+		if _, found, _ := unstructured.NestedMap(crd.Object, "spec", "conversion"); found {
+			t.Errorf("%s: expected no conversion", name)
+		}
+		// the versions have the same schema, so it is moved to the top level validation
+		if _, found, _ := unstructured.NestedMap(crd.Object, "spec", "validation", "openAPIV3Schema"); !found {
+			t.Errorf("%s: expected a top level validation schema", name)
+		}
+		if _, found, _ := unstructured.NestedMap(crd.Object, "spec", "subresources", "status"); !found {
+			t.Errorf("%s: expected top level status subresource", name)
+		}
+		versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+		if len(versions) != 2 {
+			t.Errorf("%s: expected 2 versions, got %d", name, len(versions))
+		}
+		for _, v := range versions {
+			version := v.(map[string]interface{})
+			for _, field := range []string{"schema", "subresources", "additionalPrinterColumns"} {
+				if version[field] != nil {
+					t.Errorf("%s: expected %s of version %s to move to the top level", name, field, version["name"])
+				}
+			}
+		}
+		columns, _, _ := unstructured.NestedSlice(crd.Object, "spec", "additionalPrinterColumns")
+		for _, c := range columns {
+			column := c.(map[string]interface{})
+			if column["JSONPath"] == nil || column["jsonPath"] != nil {
+				t.Errorf("%s: expected the v1beta1 JSONPath of printer column %v", name, column["name"])
+			}
+		}
+	}
+}
+
+func TestCrdToV1beta1DifferentSchemas(t *testing.T) {
+	crd := loadCrd(bundle.File_deploy_crds_noobaa_v1_bucketclass_crd_yaml, CrdsAPIVersionV1, testNamespace)
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	versions[0].(map[string]interface{})["schema"] = map[string]interface{}{
+		"openAPIV3Schema": map[string]interface{}{"type": "object"},
+	}
+	if err := unstructured.SetNestedSlice(crd.Object, versions, "spec", "versions"); err != nil {
+		t.Fatal(err)
+	}
+	crdToV1beta1(crd)
+	if _, found, _ := unstructured.NestedMap(crd.Object, "spec", "validation"); found {
+		t.Errorf("expected no top level validation when the schemas differ")
+	}
+	versions, _, _ = unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range versions {
+		version := v.(map[string]interface{})
+		if version["schema"] == nil {
+			t.Errorf("expected the schema of version %s to be kept", version["name"])
+		}
+	}
+}
@@ -11,10 +11,13 @@ import (
 	"github.com/noobaa/noobaa-operator/build/_output/bundle"
 	"github.com/noobaa/noobaa-operator/pkg/system"
 	"github.com/noobaa/noobaa-operator/pkg/util"
+	"github.com/noobaa/noobaa-operator/pkg/webhook"
 
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/cli-runtime/pkg/printers"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -50,6 +53,7 @@ func (cli *CLI) OperatorUninstall() {
 	util.KubeDelete(cli.Client, c.ClusterRole)
 	util.KubeDelete(cli.Client, c.ClusterRoleBinding)
 	util.KubeDelete(cli.Client, c.Deployment)
+	util.KubeDelete(cli.Client, c.ValidatingWebhookConfiguration)
 }
 
 func (cli *CLI) OperatorLocalUninstall() {
@@ -60,6 +64,7 @@ func (cli *CLI) OperatorLocalUninstall() {
 	util.KubeDelete(cli.Client, c.RoleBinding)
 	util.KubeDelete(cli.Client, c.ClusterRole)
 	util.KubeDelete(cli.Client, c.ClusterRoleBinding)
+	util.KubeDelete(cli.Client, c.ValidatingWebhookConfiguration)
 }
 
 func (cli *CLI) OperatorStatus() {
@@ -120,6 +125,10 @@ type OperatorConf struct {
 	ClusterRole        *rbacv1.ClusterRole
 	ClusterRoleBinding *rbacv1.ClusterRoleBinding
 	Deployment         *appsv1.Deployment
+
+	// ValidatingWebhookConfiguration is installed by the operator on startup,
+	// and since it is cluster scoped it is not deleted with the namespace.
+	ValidatingWebhookConfiguration *admissionregistrationv1beta1.ValidatingWebhookConfiguration
 }
 
 func (cli *CLI) loadOperatorConf() *OperatorConf {
@@ -142,6 +151,10 @@ func (cli *CLI) loadOperatorConf() *OperatorConf {
 		c.ClusterRoleBinding.Subjects[i].Namespace = cli.Namespace
 	}
 	c.Deployment.Namespace = cli.Namespace
+	c.ValidatingWebhookConfiguration = &admissionregistrationv1beta1.ValidatingWebhookConfiguration{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "admissionregistration.k8s.io/v1beta1", Kind: "ValidatingWebhookConfiguration"},
+		ObjectMeta: metav1.ObjectMeta{Name: webhook.ValidatingWebhookConfigName(cli.Namespace)},
+	}
 	c.Deployment.Spec.Template.Spec.Containers[0].Image = cli.OperatorImage
 	if cli.ImagePullSecret != "" {
 		c.Deployment.Spec.Template.Spec.ImagePullSecrets =
@@ -7,13 +7,13 @@ import (
 	"time"
 
 	"github.com/noobaa/noobaa-operator/build/_output/bundle"
-	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1"
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
 	"github.com/noobaa/noobaa-operator/pkg/nb"
 	"github.com/noobaa/noobaa-operator/pkg/system"
 	"github.com/noobaa/noobaa-operator/pkg/util"
 
-	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -26,7 +26,7 @@ import (
 // LoadSystemDefaults loads a noobaa system CR from bundled yamls
 // and apply's changes from CLI flags to the defaults.
 func (cli *CLI) LoadSystemDefaults() *nbv1.NooBaa {
-	sys := util.KubeObject(bundle.File_deploy_crds_noobaa_v1alpha1_noobaa_cr_yaml).(*nbv1.NooBaa)
+	sys := util.KubeObject(bundle.File_deploy_crds_noobaa_v1_noobaa_cr_yaml).(*nbv1.NooBaa)
 	sys.Namespace = cli.Namespace
 	sys.Name = cli.SystemName
 	if cli.NooBaaImage != "" {
@@ -40,6 +40,13 @@ func (cli *CLI) LoadSystemDefaults() *nbv1.NooBaa {
 		sc := cli.StorageClassName
 		sys.Spec.StorageClassName = &sc
 	}
+	if cli.ExternalDBSecret != "" {
+		sys.Spec.DBType = nbv1.DBTypeExternal
+		sys.Spec.ExternalDBSecret = &corev1.LocalObjectReference{Name: cli.ExternalDBSecret}
+	}
+	if cli.CleanupPolicy != "" {
+		sys.Spec.CleanupPolicy = nbv1.CleanupPolicy(cli.CleanupPolicy)
+	}
 	return sys
 }
 
@@ -64,25 +71,70 @@ func (cli *CLI) SystemDelete() {
 		},
 	}
 
-	util.KubeDelete(cli.Client, sys)
+	if !util.KubeDelete(cli.Client, sys) {
+		return
+	}
+	cli.SystemWaitDeleted()
+}
 
-	// TEMPORARY ? delete PVCs here because we couldn't own them in openshift
-	// See https://github.com/noobaa/noobaa-operator/issues/12
-	// So we delete the PVC here on system delete.
-	coreApp := util.KubeObject(bundle.File_deploy_internal_statefulset_core_yaml).(*appsv1.StatefulSet)
-	for i := range coreApp.Spec.VolumeClaimTemplates {
-		t := &coreApp.Spec.VolumeClaimTemplates[i]
-		pvc := &corev1.PersistentVolumeClaim{
-			TypeMeta: metav1.TypeMeta{Kind: "PersistentVolumeClaim"},
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      t.Name + "-" + cli.SystemName + "-core-0",
-				Namespace: cli.Namespace,
-			},
+// SystemWaitDeleted waits until the operator runs the cleanup policy of the system and releases it
+func (cli *CLI) SystemWaitDeleted() {
+	intervalSec := time.Duration(3)
+	err := wait.PollImmediate(intervalSec*time.Second, system.CleanupTimeout+5*time.Minute, func() (bool, error) {
+		sys := &nbv1.NooBaa{}
+		err := cli.Client.Get(cli.Ctx, client.ObjectKey{Namespace: cli.Namespace, Name: cli.SystemName}, sys)
+		if errors.IsNotFound(err) {
+			cli.Log.Printf("✅ System deleted.\n")
+			return true, nil
+		}
+		if err != nil {
+			return false, err
 		}
-		util.KubeDelete(cli.Client, pvc)
+		cli.Log.Printf("⏳ System Phase is \"%s\". Waiting for the operator to cleanup (policy \"%s\") ...\n",
+			sys.Status.Phase, sys.Spec.CleanupPolicy)
+		return false, nil
+	})
+	if err != nil {
+		cli.Log.Errorf("❌ System was not deleted: %s (is the operator running?)", err)
 	}
 }
 
+// SystemRotateAdminPassword runs a CLI command
+func (cli *CLI) SystemRotateAdminPassword() {
+	cli.SystemRequestRotation(system.AnnotationRotateAdminPassword)
+}
+
+// SystemRequestRotation sets a rotation annotation on the system
+// and waits until the operator rotates the credentials and removes it.
+func (cli *CLI) SystemRequestRotation(annotation string) {
+	sys := &nbv1.NooBaa{}
+	key := client.ObjectKey{Namespace: cli.Namespace, Name: cli.SystemName}
+	util.Panic(cli.Client.Get(cli.Ctx, key, sys))
+	if sys.Annotations == nil {
+		sys.Annotations = map[string]string{}
+	}
+	sys.Annotations[annotation] = time.Now().UTC().Format(time.RFC3339)
+	util.Panic(cli.Client.Update(cli.Ctx, sys))
+	cli.Log.Printf("Requested rotation by annotation %s on system %s\n", annotation, cli.SystemName)
+
+	intervalSec := time.Duration(3)
+	util.Panic(wait.PollImmediate(intervalSec*time.Second, 10*time.Minute, func() (bool, error) {
+		sys := &nbv1.NooBaa{}
+		err := cli.Client.Get(cli.Ctx, key, sys)
+		if err != nil {
+			return false, err
+		}
+		if _, ok := sys.Annotations[annotation]; !ok {
+			cli.Log.Printf("✅ Rotation completed, the new credentials are in secret %s\n",
+				sys.Status.Accounts.Admin.SecretRef.Name)
+			return true, nil
+		}
+		cli.Log.Printf("⏳ System Phase is \"%s\". Waiting for the operator to rotate the credentials ...\n",
+			sys.Status.Phase)
+		return false, nil
+	}))
+}
+
 // SystemList runs a CLI command
 func (cli *CLI) SystemList() {
 	list := nbv1.NooBaaList{}
@@ -32,7 +32,7 @@ const (
 var (
 	namespace       = os.Getenv("WATCH_NAMESPACE")
 	logger          = logrus.WithFields(logrus.Fields{"mod": "bucket-provisioner"})
-	provisionerName = "noobaa.io/" + namespace + ".bucket"
+	provisionerName = system.BucketProvisionerName(namespace)
 )
 
 type noobaaBucketProvisioner struct {
@@ -104,11 +104,11 @@ func (p *noobaaBucketProvisioner) createAccountForBucket() error {
 	timeSuffix := time.Now().Unix()
 	name := fmt.Sprintf("%s.account-%v@noobaa.io", p.bucketName, timeSuffix)
 	accountInfo, err := p.nbClient.CreateAccountAPI(nb.CreateAccountParams{
-		Name:              name,
-		Email:             name,
-		HasLogin:          false,
-		S3Access:          true,
-		AllowBucketCreate: false,
+		Name:                name,
+		Email:               name,
+		HasLogin:            false,
+		S3Access:            true,
+		AllowBucketCreation: false,
 		AllowedBuckets: nb.AccountAllowedBuckets{
 			FullPermission: false,
 			PermissionList: []string{p.bucketName},
@@ -234,7 +234,10 @@ func createConfigAndClient() (*restclient.Config, *kubernetes.Clientset, error)
 
 func (p *noobaaBucketProvisioner) initNoobaaInfo() error {
 	s := system.New(types.NamespacedName{Namespace: namespace, Name: "noobaa"}, p.client, p.scheme, nil)
-	s.Load()
+	if err := s.LoadObjects(); err != nil {
+		logger.Error(err, "Failed to load system")
+		return err
+	}
 
 	mgmtStatus := s.NooBaa.Status.Services.ServiceMgmt
 	if len(mgmtStatus.NodePorts) == 0 {
@@ -10,6 +10,7 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"github.com/noobaa/noobaa-operator/pkg/apis"
+	"github.com/noobaa/noobaa-operator/pkg/webhook"
 
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	"github.com/operator-framework/operator-sdk/pkg/leader"
@@ -85,6 +86,12 @@ func OperatorMain() {
 		os.Exit(1)
 	}
 
+	// Setup the admission webhooks
+	if err := webhook.Add(mgr, namespace); err != nil {
+		logrus.WithError(err).Errorln("Failed adding webhook server")
+		os.Exit(1)
+	}
+
 	// Create Service object to expose the metrics port.
 	_, err = metrics.ExposeMetricsPort(ctx, metricsPort)
 	if err != nil {
@@ -1,14 +1,23 @@
 package noobaa
 
 import (
+	"context"
+	"reflect"
+
 	"github.com/noobaa/noobaa-operator/pkg/system"
 
-	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1"
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+	"github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
@@ -17,18 +26,29 @@ import (
 // The Manager will set fields on the Controller and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
 
+	// uncached client for cluster scoped objects (the manager cache is namespaced)
+	reader, err := client.New(mgr.GetConfig(), client.Options{
+		Scheme: mgr.GetScheme(),
+		Mapper: mgr.GetRESTMapper(),
+	})
+	if err != nil {
+		return err
+	}
+
 	// Create a controller that runs reconcile on noobaa system
 
 	c, err := controller.New("noobaa-controller", mgr, controller.Options{
 		MaxConcurrentReconciles: 1,
 		Reconciler: reconcile.Func(
 			func(req reconcile.Request) (reconcile.Result, error) {
-				return system.New(
+				s := system.New(
 					req.NamespacedName,
 					mgr.GetClient(),
 					mgr.GetScheme(),
 					mgr.GetRecorder("noobaa-operator"),
-				).Reconcile()
+				)
+				s.ClusterReader = reader
+				return s.Reconcile()
 			}),
 	})
 	if err != nil {
@@ -40,7 +60,20 @@ func Add(mgr manager.Manager) error {
 	primaryHandler := &handler.EnqueueRequestForObject{}
 	secondaryHandler := &handler.EnqueueRequestForOwner{IsController: true, OwnerType: &nbv1.NooBaa{}}
 
-	err = c.Watch(&source.Kind{Type: &nbv1.NooBaa{}}, primaryHandler)
+	// status updates of the system do not trigger reconcile, otherwise every failed reconcile
+	// would requeue immediately by its own status update and bypass the retry backoff.
+	// the status subresource keeps the generation unchanged on status updates.
+	primaryPredicate := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() ||
+				e.MetaNew.GetDeletionTimestamp() != nil ||
+				!reflect.DeepEqual(e.MetaOld.GetAnnotations(), e.MetaNew.GetAnnotations()) ||
+				!reflect.DeepEqual(e.MetaOld.GetLabels(), e.MetaNew.GetLabels()) ||
+				!reflect.DeepEqual(e.MetaOld.GetFinalizers(), e.MetaNew.GetFinalizers())
+		},
+	}
+
+	err = c.Watch(&source.Kind{Type: &nbv1.NooBaa{}}, primaryHandler, primaryPredicate)
 	if err != nil {
 		return err
 	}
@@ -57,5 +90,81 @@ func Add(mgr manager.Manager) error {
 		return err
 	}
 
-	return nil
+	// Referenced config maps (CA bundles), secrets (TLS certificates) and backing stores
+	// are not owned by the system, so changes to these trigger reconcile of all the systems in the namespace
+
+	namespaceHandler := &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			return systemsInNamespace(mgr, obj.Meta.GetNamespace())
+		}),
+	}
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, namespaceHandler)
+	if err != nil {
+		return err
+	}
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, namespaceHandler)
+	if err != nil {
+		return err
+	}
+	err = c.Watch(&source.Kind{Type: &nbv1.BackingStore{}}, namespaceHandler)
+	if err != nil {
+		return err
+	}
+
+	// Periodically read the stats of ready systems to export them as operator metrics
+
+	return mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		wait.Until(func() { collectStats(mgr, reader) }, system.StatsInterval, stop)
+		return nil
+	}))
+}
+
+// systemsInNamespace returns reconcile requests for all the systems in the namespace
+func systemsInNamespace(mgr manager.Manager, namespace string) []reconcile.Request {
+	list := &nbv1.NooBaaList{}
+	err := mgr.GetClient().List(context.TODO(), &client.ListOptions{Namespace: namespace}, list)
+	if err != nil {
+		logrus.Warnf("Failed to list systems in namespace %s: %v", namespace, err)
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+			Namespace: list.Items[i].Namespace,
+			Name:      list.Items[i].Name,
+		}})
+	}
+	return requests
+}
+
+// collectStats reads the stats of all the ready systems in the watched namespace
+func collectStats(mgr manager.Manager, reader client.Client) {
+	list := &nbv1.NooBaaList{}
+	err := mgr.GetClient().List(context.TODO(), &client.ListOptions{}, list)
+	if err != nil {
+		logrus.Warnf("Stats collection failed to list systems: %v", err)
+		return
+	}
+	for i := range list.Items {
+		nooBaa := &list.Items[i]
+		if nooBaa.Status.Phase != nbv1.SystemPhaseReady {
+			continue
+		}
+		s := system.New(
+			types.NamespacedName{Namespace: nooBaa.Namespace, Name: nooBaa.Name},
+			mgr.GetClient(),
+			mgr.GetScheme(),
+			nil,
+		)
+		err := s.LoadObjects()
+		if err == nil {
+			err = s.InitNooBaaClient()
+		}
+		if err == nil {
+			err = s.CollectStats(reader)
+		}
+		if err != nil {
+			s.Logger.Warnf("Stats collection failed: %v", err)
+		}
+	}
 }
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/noobaa/noobaa-operator/pkg/util"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+// This tool converts the CRD yamls in the given dirs to apiextensions.k8s.io/v1 with structural schemas.
+// It runs after "operator-sdk generate openapi" which generates apiextensions.k8s.io/v1beta1 CRDs,
+// and can run again on converted yamls to update them.
+//
+// Usage: go run pkg/crd/crd.go deploy/crds/ deploy/manual_crds/
+//
+// Structural schemas are required by apiextensions.k8s.io/v1, which also prunes unknown fields,
+// so objects without declared properties (such as the embedded kubernetes types which are not
+// expanded by the generator) preserve their unknown fields instead of being pruned to empty objects.
+//
+// The generator writes a CRD file per API version, so the files of the same CRD are merged
+// into a single CRD with all the versions (see mergeVersions).
+
+const (
+	apiextV1      = "apiextensions.k8s.io/v1"
+	apiextV1beta1 = "apiextensions.k8s.io/v1beta1"
+)
+
+func main() {
+
+	util.InitLogger()
+
+	for _, dir := range os.Args[1:] {
+		files, err := filepath.Glob(filepath.Join(dir, "*crd.yaml"))
+		fatal(err)
+		crds := map[string][]*crdFile{}
+		names := []string{}
+		for _, path := range files {
+			bytes, err := ioutil.ReadFile(path)
+			fatal(err)
+			crd := map[string]interface{}{}
+			fatal(yaml.Unmarshal(bytes, &crd))
+			if crd["kind"] != "CustomResourceDefinition" {
+				continue
+			}
+			convertCRD(crd)
+			name := crd["metadata"].(map[string]interface{})["name"].(string)
+			if crds[name] == nil {
+				names = append(names, name)
+			}
+			crds[name] = append(crds[name], &crdFile{path: path, crd: crd})
+		}
+		for _, name := range names {
+			f := mergeVersions(crds[name])
+			out, err := yaml.Marshal(f.crd)
+			fatal(err)
+			fatal(ioutil.WriteFile(f.path, out, 0644))
+			logrus.Printf("crd %s converted to %s\n", f.path, apiextV1)
+			for _, other := range crds[name] {
+				if other.path != f.path {
+					fatal(os.Remove(other.path))
+					logrus.Printf("crd %s merged into %s\n", other.path, f.path)
+				}
+			}
+		}
+	}
+	logrus.Printf("crd - done.\n")
+}
+
+type crdFile struct {
+	path string
+	crd  map[string]interface{}
+}
+
+// mergeVersions merges the files of the same CRD, since the generator writes a file per API version,
+// into the file of the newest version which becomes the storage version.
+// All the versions are served, and CRDs with multiple versions convert between them with the operator webhook.
+func mergeVersions(files []*crdFile) *crdFile {
+	versions := map[string]interface{}{}
+	newest := files[0]
+	newestName := ""
+	for _, f := range files {
+		spec := f.crd["spec"].(map[string]interface{})
+		for _, v := range spec["versions"].([]interface{}) {
+			name := v.(map[string]interface{})["name"].(string)
+			versions[name] = v
+			if newestName == "" || version.CompareKubeAwareVersionStrings(name, newestName) > 0 {
+				newestName = name
+				newest = f
+			}
+		}
+	}
+	names := []string{}
+	for name := range versions {
+		names = append(names, name)
+	}
+	// sort from the oldest to the newest version
+	sort.Slice(names, func(i, j int) bool {
+		return version.CompareKubeAwareVersionStrings(names[i], names[j]) < 0
+	})
+	list := []interface{}{}
+	for _, name := range names {
+		v := versions[name].(map[string]interface{})
+		v["served"] = true
+		v["storage"] = name == newestName
+		list = append(list, v)
+	}
+	spec := newest.crd["spec"].(map[string]interface{})
+	spec["versions"] = list
+	if len(list) > 1 {
+		spec["conversion"] = conversionWebhook()
+	} else {
+		delete(spec, "conversion")
+	}
+	return newest
+}
+
+// conversionWebhook returns the webhook conversion of the CRD which is served by the operator.
+// The service namespace is replaced by the CLI with the operator namespace,
+// and the operator sets the CA bundle of its webhook server certificate.
+// See webhook.ServiceName and webhook.ConvertPath.
+func conversionWebhook() map[string]interface{} {
+	return map[string]interface{}{
+		"strategy": "Webhook",
+		"webhook": map[string]interface{}{
+			"conversionReviewVersions": []interface{}{"v1", "v1beta1"},
+			"clientConfig": map[string]interface{}{
+				"service": map[string]interface{}{
+					"name":      "noobaa-webhook",
+					"namespace": "noobaa",
+					"path":      "/convert",
+				},
+			},
+		},
+	}
+}
+
+// convertCRD converts a v1beta1 CRD to v1 by moving the top level schema, subresources and
+// printer columns to every version, and makes the schema of every version structural
+func convertCRD(crd map[string]interface{}) {
+	spec := crd["spec"].(map[string]interface{})
+
+	if crd["apiVersion"] == apiextV1beta1 {
+		versions, _ := spec["versions"].([]interface{})
+		if len(versions) == 0 {
+			versions = []interface{}{map[string]interface{}{
+				"name":    spec["version"],
+				"served":  true,
+				"storage": true,
+			}}
+		}
+		for _, v := range versions {
+			version := v.(map[string]interface{})
+			if version["schema"] == nil && spec["validation"] != nil {
+				version["schema"] = deepCopy(spec["validation"])
+			}
+			if version["subresources"] == nil && spec["subresources"] != nil {
+				version["subresources"] = deepCopy(spec["subresources"])
+			}
+			if version["additionalPrinterColumns"] == nil && spec["additionalPrinterColumns"] != nil {
+				version["additionalPrinterColumns"] = deepCopy(spec["additionalPrinterColumns"])
+			}
+			columns, _ := version["additionalPrinterColumns"].([]interface{})
+			for _, c := range columns {
+				column := c.(map[string]interface{})
+				if column["JSONPath"] != nil {
+					column["jsonPath"] = column["JSONPath"]
+					delete(column, "JSONPath")
+				}
+			}
+		}
+		spec["versions"] = versions
+		delete(spec, "version")
+		delete(spec, "validation")
+		delete(spec, "subresources")
+		delete(spec, "additionalPrinterColumns")
+		if conversion, _ := spec["conversion"].(map[string]interface{}); conversion != nil && conversion["strategy"] == "None" {
+			delete(spec, "conversion")
+		}
+		crd["apiVersion"] = apiextV1
+	}
+
+	for _, v := range spec["versions"].([]interface{}) {
+		version := v.(map[string]interface{})
+		schema, _ := version["schema"].(map[string]interface{})
+		if schema == nil {
+			schema = map[string]interface{}{}
+			version["schema"] = schema
+		}
+		root, _ := schema["openAPIV3Schema"].(map[string]interface{})
+		if root == nil {
+			root = map[string]interface{}{}
+			schema["openAPIV3Schema"] = root
+		}
+		root["type"] = "object"
+		structural(root, true)
+	}
+}
+
+// structural makes a schema node structural:
+// - objects without properties preserve unknown fields (instead of being pruned)
+// - anyOf string or integer (IntOrString) is replaced by x-kubernetes-int-or-string
+func structural(node map[string]interface{}, isRoot bool) {
+
+	if anyOf, _ := node["anyOf"].([]interface{}); anyOf != nil && isIntOrString(anyOf) {
+		delete(node, "anyOf")
+		node["x-kubernetes-int-or-string"] = true
+	}
+
+	if node["type"] == "object" && node["properties"] == nil && node["additionalProperties"] == nil {
+		node["x-kubernetes-preserve-unknown-fields"] = true
+	}
+
+	if props, _ := node["properties"].(map[string]interface{}); props != nil {
+		for name, p := range props {
+			prop := p.(map[string]interface{})
+			// the metadata schema of the root only allows restrictions on name and generateName
+			if isRoot && name == "metadata" {
+				continue
+			}
+			structural(prop, false)
+		}
+	}
+	if additional, _ := node["additionalProperties"].(map[string]interface{}); additional != nil {
+		structural(additional, false)
+	}
+	if items, _ := node["items"].(map[string]interface{}); items != nil {
+		structural(items, false)
+	}
+}
+
+func isIntOrString(anyOf []interface{}) bool {
+	types := []string{}
+	for _, a := range anyOf {
+		t, _ := a.(map[string]interface{})["type"].(string)
+		types = append(types, t)
+	}
+	return strings.Join(types, ",") == "string,integer" || strings.Join(types, ",") == "integer,string"
+}
+
+func deepCopy(in interface{}) interface{} {
+	bytes, err := json.Marshal(in)
+	fatal(err)
+	var out interface{}
+	fatal(json.Unmarshal(bytes, &out))
+	return out
+}
+
+func fatal(err error) {
+	if err != nil {
+		logrus.Fatalln(err)
+	}
+}
@@ -0,0 +1,427 @@
+// Package main generates the typed noobaa api client from the api schemas of noobaa-core.
+//
+// The schemas directory holds one json file per api, exported from the
+// api definitions of noobaa-core (src/api/*_api.js) which are plain json schemas
+// with a map of methods, each with optional params and reply schemas.
+// The generator writes the request/reply types, the RPCClient methods and
+// the APIClient interface to a single go file in package nb.
+//
+// Usage: go run pkg/nb/apigen/apigen.go <schemas-dir> <output-file>
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/noobaa/noobaa-operator/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+// API is the json structure of a noobaa-core api schema
+type API struct {
+	ID          string             `json:"id"`
+	Methods     map[string]*Method `json:"methods"`
+	Definitions map[string]*Schema `json:"definitions"`
+}
+
+// Method is the json structure of a single api method
+type Method struct {
+	Method string  `json:"method"`
+	Doc    string  `json:"doc"`
+	Params *Schema `json:"params"`
+	Reply  *Schema `json:"reply"`
+}
+
+// Schema is the subset of json schema (with noobaa-core extensions) that the generator understands
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 interface{}        `json:"type"`
+	Doc                  string             `json:"doc"`
+	Description          string             `json:"description"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties interface{}        `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	Enum                 []interface{}      `json:"enum"`
+	OneOf                []*Schema          `json:"oneOf"`
+	AnyOf                []*Schema          `json:"anyOf"`
+	IDate                bool               `json:"idate"`
+	Date                 bool               `json:"date"`
+	ObjectID             bool               `json:"objectid"`
+}
+
+// Generator keeps the loaded apis and the names used in the generated package
+type Generator struct {
+	APIs  map[string]*API
+	Names map[string]string
+	Out   bytes.Buffer
+}
+
+var initialisms = map[string]string{
+	"api":  "API",
+	"cpu":  "CPU",
+	"dns":  "DNS",
+	"http": "HTTP",
+	"id":   "ID",
+	"ip":   "IP",
+	"json": "JSON",
+	"ssl":  "SSL",
+	"tls":  "TLS",
+	"uid":  "UID",
+	"url":  "URL",
+}
+
+// customTypes are definitions that are implemented by hand in package nb
+// because their json encoding cannot be expressed by a generated type.
+var customTypes = map[string]string{
+	"common_api#/definitions/bigint": "BigInt",
+}
+
+func main() {
+
+	util.InitLogger()
+
+	src := os.Args[1]
+	out := os.Args[2]
+	logrus.Printf("apigen schemas in %s writing to %s\n", src, out)
+
+	g := &Generator{
+		APIs:  map[string]*API{},
+		Names: map[string]string{},
+	}
+	g.Load(src)
+	g.Generate()
+
+	formatted, err := format.Source(g.Out.Bytes())
+	if err != nil {
+		logrus.Errorf("apigen generated invalid code:\n%s", g.Out.String())
+	}
+	fatal(err)
+	fatal(ioutil.WriteFile(out, formatted, 0644))
+	logrus.Printf("apigen - done.\n")
+}
+
+// Load reads all the api json files in the schemas dir
+func (g *Generator) Load(dir string) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	fatal(err)
+	for _, file := range files {
+		bytes, err := ioutil.ReadFile(file)
+		fatal(err)
+		api := &API{}
+		fatal(json.Unmarshal(bytes, api))
+		if api.ID == "" {
+			fatal(fmt.Errorf("api schema %s is missing an id", file))
+		}
+		logrus.Printf("apigen loaded %s methods:%d definitions:%d\n",
+			api.ID, len(api.Methods), len(api.Definitions))
+		g.APIs[api.ID] = api
+	}
+}
+
+// Generate writes the go code of all the apis
+func (g *Generator) Generate() {
+
+	apiIDs := sortedKeys(g.APIs)
+
+	g.printf("// Code generated by apigen from the noobaa-core api schemas. DO NOT EDIT.\n\n")
+	g.printf("package nb\n\n")
+
+	g.printf("// These are the api names served by noobaa-core\n")
+	g.printf("const (\n")
+	for _, id := range apiIDs {
+		name := g.reserve(goName(id), id)
+		g.printf("\t// %s is the name of %s\n", name, id)
+		g.printf("\t%s = %q\n", name, id)
+	}
+	g.printf(")\n\n")
+
+	g.printf("// APIClient is the interface of the typed api calls generated from the schemas\n")
+	g.printf("type APIClient interface {\n")
+	for _, id := range apiIDs {
+		api := g.APIs[id]
+		for _, m := range sortedKeys(api.Methods) {
+			method := api.Methods[m]
+			funcName := goName(m) + "API"
+			if method.Params != nil {
+				g.printf("\t%s(%s) (%s, error)\n", funcName, goName(m)+"Params", goName(m)+"Reply")
+			} else {
+				g.printf("\t%s() (%s, error)\n", funcName, goName(m)+"Reply")
+			}
+		}
+	}
+	g.printf("}\n\n")
+
+	for _, id := range apiIDs {
+		api := g.APIs[id]
+		if len(api.Methods) == 0 && len(api.Definitions) == 0 {
+			continue
+		}
+		title := strings.ToUpper(strings.TrimSuffix(id, "_api"))
+		g.printf("%s\n// %s //\n%s\n\n",
+			strings.Repeat("/", len(title)+6), title, strings.Repeat("/", len(title)+6))
+		for _, d := range sortedKeys(api.Definitions) {
+			g.GenerateDefinition(api, d)
+		}
+		for _, m := range sortedKeys(api.Methods) {
+			g.GenerateMethod(api, m)
+		}
+	}
+}
+
+// GenerateDefinition writes a named type for a schema definition
+func (g *Generator) GenerateDefinition(api *API, def string) {
+	if _, ok := customTypes[api.ID+"#/definitions/"+def]; ok {
+		return
+	}
+	schema := api.Definitions[def]
+	name := g.reserve(goName(def), api.ID+"#/definitions/"+def)
+	g.printDoc(schema, fmt.Sprintf("%s is the definition %s of %s", name, def, api.ID))
+	g.printf("type %s %s\n\n", name, g.goTopType(api, schema))
+}
+
+// GenerateMethod writes the params and reply types and the RPCClient method
+func (g *Generator) GenerateMethod(api *API, m string) {
+	method := api.Methods[m]
+	call := fmt.Sprintf("%s.%s()", api.ID, m)
+	funcName := g.reserve(goName(m)+"API", call)
+	paramsName := ""
+
+	if method.Params != nil {
+		paramsName = g.reserve(goName(m)+"Params", call)
+		g.printf("// %s is the params of %s\n", paramsName, call)
+		g.printf("type %s %s\n\n", paramsName, g.goTopType(api, method.Params))
+	}
+
+	replyName := g.reserve(goName(m)+"Reply", call)
+	g.printf("// %s is the reply of %s\n", replyName, call)
+	if method.Reply != nil {
+		g.printf("type %s %s\n\n", replyName, g.goTopType(api, method.Reply))
+	} else {
+		g.printf("type %s struct{}\n\n", replyName)
+	}
+
+	if method.Doc != "" {
+		g.printf("// %s calls %s - %s\n", funcName, call, method.Doc)
+	} else {
+		g.printf("// %s calls %s\n", funcName, call)
+	}
+	if paramsName != "" {
+		g.printf("func (c *RPCClient) %s(params %s) (%s, error) {\n", funcName, paramsName, replyName)
+		g.printf("\treq := RPCRequest{API: %q, Method: %q, Params: params}\n", api.ID, m)
+	} else {
+		g.printf("func (c *RPCClient) %s() (%s, error) {\n", funcName, replyName)
+		g.printf("\treq := RPCRequest{API: %q, Method: %q}\n", api.ID, m)
+	}
+	g.printf("\tres := struct {\n")
+	g.printf("\t\tRPCResponse `json:\",inline\"`\n")
+	g.printf("\t\tReply %s `json:\"reply\"`\n", replyName)
+	g.printf("\t}{}\n")
+	g.printf("\terr := c.Call(req, &res)\n")
+	g.printf("\treturn res.Reply, err\n")
+	g.printf("}\n\n")
+}
+
+// goTopType is like goType but keeps empty objects as structs
+// so that named params/reply types are always structs when possible.
+func (g *Generator) goTopType(api *API, schema *Schema) string {
+	if schemaType(schema) == "object" && len(schema.Properties) == 0 && schema.AdditionalProperties == nil {
+		return "struct{}"
+	}
+	return g.goType(api, schema)
+}
+
+// goType returns the go type expression for a schema
+func (g *Generator) goType(api *API, schema *Schema) string {
+	if schema == nil {
+		return "interface{}"
+	}
+	if schema.Ref != "" {
+		return g.goRefType(api, schema.Ref)
+	}
+	if schema.ObjectID {
+		return "string"
+	}
+	if schema.IDate || schema.Date {
+		return "int64"
+	}
+	switch schemaType(schema) {
+	case "string":
+		return "string"
+	case "boolean":
+		return "bool"
+	case "integer":
+		return "int64"
+	case "number":
+		return "float64"
+	case "array":
+		return "[]" + g.goType(api, schema.Items)
+	case "object":
+		if len(schema.Properties) == 0 {
+			if additional, ok := schema.AdditionalProperties.(map[string]interface{}); ok {
+				b, err := json.Marshal(additional)
+				fatal(err)
+				valueSchema := &Schema{}
+				fatal(json.Unmarshal(b, valueSchema))
+				return "map[string]" + g.goType(api, valueSchema)
+			}
+			return "map[string]interface{}"
+		}
+		return g.goStruct(api, schema)
+	}
+	return "interface{}"
+}
+
+// goStruct returns an anonymous struct type for an object schema
+func (g *Generator) goStruct(api *API, schema *Schema) string {
+	required := map[string]bool{}
+	for _, r := range schema.Required {
+		required[r] = true
+	}
+	var b strings.Builder
+	b.WriteString("struct {\n")
+	for _, prop := range sortedKeys(schema.Properties) {
+		propSchema := schema.Properties[prop]
+		propType := g.goType(api, propSchema)
+		tag := prop
+		// omitempty is used only for optional fields where the zero value means unset.
+		// false/0 are meaningful values for the server (e.g. allow_bucket_creation defaults to true)
+		// and omitempty has no effect on structs anyway.
+		if !required[prop] && isOmittable(propType) {
+			tag += ",omitempty"
+		}
+		if doc := schemaDoc(propSchema); doc != "" {
+			fmt.Fprintf(&b, "// %s\n", doc)
+		}
+		fmt.Fprintf(&b, "%s %s `json:\"%s\"`\n", goName(prop), propType, tag)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// goRefType resolves "#/definitions/x" and "other_api#/definitions/x" refs
+func (g *Generator) goRefType(api *API, ref string) string {
+	refAPI, _ := g.resolveRef(api, ref)
+	def := ref[strings.Index(ref, "#/definitions/")+len("#/definitions/"):]
+	if custom, ok := customTypes[refAPI.ID+"#/definitions/"+def]; ok {
+		return custom
+	}
+	return goName(def)
+}
+
+// resolveRef returns the api and the definition schema that the ref points to
+func (g *Generator) resolveRef(api *API, ref string) (*API, *Schema) {
+	parts := strings.SplitN(ref, "#/definitions/", 2)
+	if len(parts) != 2 {
+		fatal(fmt.Errorf("unsupported $ref %q in %s", ref, api.ID))
+	}
+	refAPI := api
+	if parts[0] != "" {
+		refAPI = g.APIs[parts[0]]
+	}
+	if refAPI == nil || refAPI.Definitions[parts[1]] == nil {
+		fatal(fmt.Errorf("unresolved $ref %q in %s", ref, api.ID))
+	}
+	return refAPI, refAPI.Definitions[parts[1]]
+}
+
+func (g *Generator) printDoc(schema *Schema, fallback string) {
+	if doc := schemaDoc(schema); doc != "" {
+		g.printf("// %s\n", doc)
+	} else {
+		g.printf("// %s\n", fallback)
+	}
+}
+
+// reserve registers a package level name and fails on collisions
+// between different apis or definitions that map to the same go name.
+func (g *Generator) reserve(name string, owner string) string {
+	if prev, exists := g.Names[name]; exists && prev != owner {
+		fatal(fmt.Errorf("name collision %s used by %s and %s", name, prev, owner))
+	}
+	g.Names[name] = owner
+	return name
+}
+
+func (g *Generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.Out, format, args...)
+}
+
+// goName converts snake_case to CamelCase with common initialisms
+func goName(s string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' || r == '.' }) {
+		if initialism, ok := initialisms[strings.ToLower(word)]; ok {
+			b.WriteString(initialism)
+		} else {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+// isOmittable checks if the zero value of a go type can be omitted from the json
+func isOmittable(goType string) bool {
+	return goType == "string" ||
+		goType == "interface{}" ||
+		strings.HasPrefix(goType, "[]") ||
+		strings.HasPrefix(goType, "map[")
+}
+
+// schemaType returns the json schema type, picking the first non null type from a list
+func schemaType(schema *Schema) string {
+	switch t := schema.Type.(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, item := range t {
+			if s, ok := item.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+	if schema.Properties != nil {
+		return "object"
+	}
+	return ""
+}
+
+func schemaDoc(schema *Schema) string {
+	if schema.Doc != "" {
+		return schema.Doc
+	}
+	return schema.Description
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	switch mm := m.(type) {
+	case map[string]*API:
+		for k := range mm {
+			keys = append(keys, k)
+		}
+	case map[string]*Method:
+		for k := range mm {
+			keys = append(keys, k)
+		}
+	case map[string]*Schema:
+		for k := range mm {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func fatal(err error) {
+	if err != nil {
+		logrus.Fatalln(err)
+	}
+}
@@ -0,0 +1,57 @@
+package nb
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PetaBytes is the unit of BigInt.Peta (2^50)
+const PetaBytes = 1024 * 1024 * 1024 * 1024 * 1024
+
+// BigInt is the common_api bigint used by noobaa-core for storage sizes.
+// Core sends it as a json number, or as an object {n,peta} for values
+// that do not fit in a javascript number, where the value is n + peta * 2^50.
+type BigInt struct {
+	N    int64 `json:"n"`
+	Peta int64 `json:"peta"`
+}
+
+// UnmarshalJSON accepts both the number and the {n,peta} encodings
+func (b *BigInt) UnmarshalJSON(data []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err == nil {
+		f, err := n.Float64()
+		if err != nil {
+			return err
+		}
+		b.Peta = int64(f / PetaBytes)
+		b.N = int64(f - float64(b.Peta)*PetaBytes)
+		return nil
+	}
+	obj := struct {
+		N    int64 `json:"n"`
+		Peta int64 `json:"peta"`
+	}{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("invalid bigint %s: %v", string(data), err)
+	}
+	b.N = obj.N
+	b.Peta = obj.Peta
+	return nil
+}
+
+// MarshalJSON writes a json number when the value is small enough for javascript numbers
+func (b BigInt) MarshalJSON() ([]byte, error) {
+	if b.Peta == 0 {
+		return json.Marshal(b.N)
+	}
+	return json.Marshal(struct {
+		N    int64 `json:"n"`
+		Peta int64 `json:"peta"`
+	}{b.N, b.Peta})
+}
+
+// Float64 returns the value as float64 (used for metrics)
+func (b BigInt) Float64() float64 {
+	return float64(b.N) + float64(b.Peta)*PetaBytes
+}
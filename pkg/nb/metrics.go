@@ -0,0 +1,50 @@
+package nb
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// These are the values of the code label for failures that do not come with an RPCError.RPCCode
+const (
+	// RPCCodeOK is the code label of successful calls
+	RPCCodeOK = "OK"
+	// RPCCodeUnknown is the code label of RPCError responses without rpc_code
+	RPCCodeUnknown = "UNKNOWN"
+	// RPCCodeSendFailed is the code label of calls that failed to send the http request
+	RPCCodeSendFailed = "SEND_FAILED"
+	// RPCCodeBadResponse is the code label of calls that failed to read or decode the http response
+	RPCCodeBadResponse = "BAD_RESPONSE"
+)
+
+var (
+	// RPCCallsTotal is a prometheus counter of the rpc calls made to noobaa servers.
+	// The code label is the RPCError.RPCCode of the response, or one of the RPCCode* constants.
+	RPCCallsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "noobaa_operator_rpc_calls_total",
+		Help: "Total number of rpc calls to noobaa servers per api, method and code",
+	}, []string{"api", "method", "code"})
+
+	// RPCCallDuration is a prometheus histogram of the rpc calls latency in seconds
+	RPCCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "noobaa_operator_rpc_call_duration_seconds",
+		Help:    "Latency of rpc calls to noobaa servers per api, method and code",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"api", "method", "code"})
+)
+
+func init() {
+	// the controller-runtime registry is served by the manager on the operator metrics port
+	metrics.Registry.MustRegister(
+		RPCCallsTotal,
+		RPCCallDuration,
+	)
+}
+
+// observeRPC records the metrics of a single rpc call
+func observeRPC(req *RPCRequest, code string, start time.Time) {
+	RPCCallsTotal.WithLabelValues(req.API, req.Method, code).Inc()
+	RPCCallDuration.WithLabelValues(req.API, req.Method, code).Observe(time.Since(start).Seconds())
+}
@@ -58,15 +58,17 @@ func FindPortByName(srv *corev1.Service, portName string) *corev1.ServicePort {
 }
 
 // GetAPIPortName maps every noobaa api name to the service port name that serves it.
+// The api names are the constants generated from the noobaa-core api schemas,
+// so if an api is renamed or removed in the core, this routing will fail to compile.
 func GetAPIPortName(api string) string {
-	if api == "object_api" || api == "func_api" {
+	switch api {
+	case ObjectAPI, FuncAPI:
 		return "md-https"
-	}
-	if api == "scrubber_api" {
+	case ScrubberAPI:
 		return "bg-https"
-	}
-	if api == "hosted_agents_api" {
+	case HostedAgentsAPI:
 		return "hosted-agents-https"
+	default:
+		return "mgmt-https"
 	}
-	return "mgmt-https"
 }
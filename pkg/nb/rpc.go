@@ -100,6 +100,7 @@ func (c *RPCClient) Call(req RPCRequest, res RPCResponseIfc) error {
 	httpRequest, err := http.NewRequest("PUT", address, bytes.NewReader(reqBytes))
 	fatal(err)
 
+	start := time.Now()
 	httpResponse, err := c.HTTPClient.Do(httpRequest)
 	defer func() {
 		if httpResponse != nil && httpResponse.Body != nil {
@@ -108,28 +109,37 @@ func (c *RPCClient) Call(req RPCRequest, res RPCResponseIfc) error {
 	}()
 	if err != nil {
 		logrus.Errorf("⚠️ RPC: %s Sending http request failed: %s", u, err)
+		observeRPC(&req, RPCCodeSendFailed, start)
 		return err
 	}
 
 	resBytes, err := ioutil.ReadAll(httpResponse.Body)
 	if err != nil {
 		logrus.Errorf("⚠️ RPC: %s Reading http response failed: %s", u, err)
+		observeRPC(&req, RPCCodeBadResponse, start)
 		return err
 	}
 
 	err = json.Unmarshal(resBytes, res)
 	if err != nil {
 		logrus.Errorf("⚠️ RPC: %s Decoding response failed: %s", u, err)
+		observeRPC(&req, RPCCodeBadResponse, start)
 		return err
 	}
 
 	r := res.Response()
 	if r.Error != nil {
 		logrus.Errorf("⚠️ RPC: %s Response Error: %s", u, r.Error)
+		code := r.Error.RPCCode
+		if code == "" {
+			code = RPCCodeUnknown
+		}
+		observeRPC(&req, code, start)
 		return r.Error
 	}
 
 	logrus.Infof("✅ RPC: %s Response OK: %#v", u, r)
+	observeRPC(&req, RPCCodeOK, start)
 	return nil
 }
 
@@ -0,0 +1,425 @@
+// Code generated by apigen from the noobaa-core api schemas. DO NOT EDIT.
+
+package nb
+
+// These are the api names served by noobaa-core
+const (
+	// AccountAPI is the name of account_api
+	AccountAPI = "account_api"
+	// AuthAPI is the name of auth_api
+	AuthAPI = "auth_api"
+	// BucketAPI is the name of bucket_api
+	BucketAPI = "bucket_api"
+	// CommonAPI is the name of common_api
+	CommonAPI = "common_api"
+	// FuncAPI is the name of func_api
+	FuncAPI = "func_api"
+	// HostedAgentsAPI is the name of hosted_agents_api
+	HostedAgentsAPI = "hosted_agents_api"
+	// ObjectAPI is the name of object_api
+	ObjectAPI = "object_api"
+	// PoolAPI is the name of pool_api
+	PoolAPI = "pool_api"
+	// ScrubberAPI is the name of scrubber_api
+	ScrubberAPI = "scrubber_api"
+	// SystemAPI is the name of system_api
+	SystemAPI = "system_api"
+)
+
+// APIClient is the interface of the typed api calls generated from the schemas
+type APIClient interface {
+	CreateAccountAPI(CreateAccountParams) (CreateAccountReply, error)
+	DeleteAccountAPI(DeleteAccountParams) (DeleteAccountReply, error)
+	GenerateAccountKeysAPI(GenerateAccountKeysParams) (GenerateAccountKeysReply, error)
+	ListAccountsAPI() (ListAccountsReply, error)
+	ReadAccountAPI(ReadAccountParams) (ReadAccountReply, error)
+	ResetPasswordAPI(ResetPasswordParams) (ResetPasswordReply, error)
+	CreateAuthAPI(CreateAuthParams) (CreateAuthReply, error)
+	ReadAuthAPI() (ReadAuthReply, error)
+	CreateBucketAPI(CreateBucketParams) (CreateBucketReply, error)
+	DeleteBucketAPI(DeleteBucketParams) (DeleteBucketReply, error)
+	DeleteBucketAndObjectsAPI(DeleteBucketAndObjectsParams) (DeleteBucketAndObjectsReply, error)
+	ListBucketsAPI() (ListBucketsReply, error)
+	CreateSystemAPI(CreateSystemParams) (CreateSystemReply, error)
+	ReadSystemAPI() (ReadSystemReply, error)
+}
+
+/////////////
+// ACCOUNT //
+/////////////
+
+// AccountAllowedBuckets is the definition account_allowed_buckets of account_api
+type AccountAllowedBuckets struct {
+	FullPermission bool     `json:"full_permission"`
+	PermissionList []string `json:"permission_list,omitempty"`
+}
+
+// AccountInfo is the definition account_info of account_api
+type AccountInfo struct {
+	AccessKeys  []S3AccessKeys `json:"access_keys,omitempty"`
+	Email       string         `json:"email"`
+	HasLogin    bool           `json:"has_login"`
+	HasS3Access bool           `json:"has_s3_access"`
+	Name        string         `json:"name"`
+}
+
+// CreateAccountParams is the params of account_api.create_account()
+type CreateAccountParams struct {
+	AllowBucketCreation bool                  `json:"allow_bucket_creation"`
+	AllowedBuckets      AccountAllowedBuckets `json:"allowed_buckets"`
+	Email               string                `json:"email"`
+	HasLogin            bool                  `json:"has_login"`
+	Name                string                `json:"name"`
+	S3Access            bool                  `json:"s3_access"`
+}
+
+// CreateAccountReply is the reply of account_api.create_account()
+type CreateAccountReply struct {
+	AccessKeys []S3AccessKeys `json:"access_keys,omitempty"`
+	Token      string         `json:"token"`
+}
+
+// CreateAccountAPI calls account_api.create_account()
+func (c *RPCClient) CreateAccountAPI(params CreateAccountParams) (CreateAccountReply, error) {
+	req := RPCRequest{API: "account_api", Method: "create_account", Params: params}
+	res := struct {
+		RPCResponse `json:",inline"`
+		Reply       CreateAccountReply `json:"reply"`
+	}{}
+	err := c.Call(req, &res)
+	return res.Reply, err
+}
+
+// DeleteAccountParams is the params of account_api.delete_account()
+type DeleteAccountParams struct {
+	Email string `json:"email"`
+}
+
+// DeleteAccountReply is the reply of account_api.delete_account()
+type DeleteAccountReply struct{}
+
+// DeleteAccountAPI calls account_api.delete_account()
+func (c *RPCClient) DeleteAccountAPI(params DeleteAccountParams) (DeleteAccountReply, error) {
+	req := RPCRequest{API: "account_api", Method: "delete_account", Params: params}
+	res := struct {
+		RPCResponse `json:",inline"`
+		Reply       DeleteAccountReply `json:"reply"`
+	}{}
+	err := c.Call(req, &res)
+	return res.Reply, err
+}
+
+// GenerateAccountKeysParams is the params of account_api.generate_account_keys()
+type GenerateAccountKeysParams struct {
+	Email string `json:"email"`
+}
+
+// GenerateAccountKeysReply is the reply of account_api.generate_account_keys()
+type GenerateAccountKeysReply struct{}
+
+// GenerateAccountKeysAPI calls account_api.generate_account_keys()
+func (c *RPCClient) GenerateAccountKeysAPI(params GenerateAccountKeysParams) (GenerateAccountKeysReply, error) {
+	req := RPCRequest{API: "account_api", Method: "generate_account_keys", Params: params}
+	res := struct {
+		RPCResponse `json:",inline"`
+		Reply       GenerateAccountKeysReply `json:"reply"`
+	}{}
+	err := c.Call(req, &res)
+	return res.Reply, err
+}
+
+// ListAccountsReply is the reply of account_api.list_accounts()
+type ListAccountsReply struct {
+	Accounts []AccountInfo `json:"accounts"`
+}
+
+// ListAccountsAPI calls account_api.list_accounts()
+func (c *RPCClient) ListAccountsAPI() (ListAccountsReply, error) {
+	req := RPCRequest{API: "account_api", Method: "list_accounts"}
+	res := struct {
+		RPCResponse `json:",inline"`
+		Reply       ListAccountsReply `json:"reply"`
+	}{}
+	err := c.Call(req, &res)
+	return res.Reply, err
+}
+
+// ReadAccountParams is the params of account_api.read_account()
+type ReadAccountParams struct {
+	Email string `json:"email"`
+}
+
+// ReadAccountReply is the reply of account_api.read_account()
+type ReadAccountReply AccountInfo
+
+// ReadAccountAPI calls account_api.read_account()
+func (c *RPCClient) ReadAccountAPI(params ReadAccountParams) (ReadAccountReply, error) {
+	req := RPCRequest{API: "account_api", Method: "read_account", Params: params}
+	res := struct {
+		RPCResponse `json:",inline"`
+		Reply       ReadAccountReply `json:"reply"`
+	}{}
+	err := c.Call(req, &res)
+	return res.Reply, err
+}
+
+// ResetPasswordParams is the params of account_api.reset_password()
+type ResetPasswordParams struct {
+	Email                string `json:"email"`
+	MustChangePassword   bool   `json:"must_change_password"`
+	Password             string `json:"password"`
+	VerificationPassword string `json:"verification_password"`
+}
+
+// ResetPasswordReply is the reply of account_api.reset_password()
+type ResetPasswordReply struct{}
+
+// ResetPasswordAPI calls account_api.reset_password()
+func (c *RPCClient) ResetPasswordAPI(params ResetPasswordParams) (ResetPasswordReply, error) {
+	req := RPCRequest{API: "account_api", Method: "reset_password", Params: params}
+	res := struct {
+		RPCResponse `json:",inline"`
+		Reply       ResetPasswordReply `json:"reply"`
+	}{}
+	err := c.Call(req, &res)
+	return res.Reply, err
+}
+
+//////////
+// AUTH //
+//////////
+
+// CreateAuthParams is the params of auth_api.create_auth()
+type CreateAuthParams struct {
+	Email    string `json:"email"`
+	Password string `json:"password,omitempty"`
+	Role     string `json:"role"`
+	System   string `json:"system"`
+}
+
+// CreateAuthReply is the reply of auth_api.create_auth()
+type CreateAuthReply struct {
+	Token string `json:"token"`
+}
+
+// CreateAuthAPI calls auth_api.create_auth()
+func (c *RPCClient) CreateAuthAPI(params CreateAuthParams) (CreateAuthReply, error) {
+	req := RPCRequest{API: "auth_api", Method: "create_auth", Params: params}
+	res := struct {
+		RPCResponse `json:",inline"`
+		Reply       CreateAuthReply `json:"reply"`
+	}{}
+	err := c.Call(req, &res)
+	return res.Reply, err
+}
+
+// ReadAuthReply is the reply of auth_api.read_auth()
+type ReadAuthReply struct {
+	Account struct {
+		Email              string `json:"email"`
+		IsSupport          bool   `json:"is_support"`
+		MustChangePassword bool   `json:"must_change_password"`
+		Name               string `json:"name"`
+	} `json:"account"`
+	AuthorizedBy string                 `json:"authorized_by,omitempty"`
+	Extra        map[string]interface{} `json:"extra,omitempty"`
+	Role         string                 `json:"role,omitempty"`
+	System       struct {
+		Name string `json:"name"`
+	} `json:"system"`
+}
+
+// ReadAuthAPI calls auth_api.read_auth()
+func (c *RPCClient) ReadAuthAPI() (ReadAuthReply, error) {
+	req := RPCRequest{API: "auth_api", Method: "read_auth"}
+	res := struct {
+		RPCResponse `json:",inline"`
+		Reply       ReadAuthReply `json:"reply"`
+	}{}
+	err := c.Call(req, &res)
+	return res.Reply, err
+}
+
+////////////
+// BUCKET //
+////////////
+
+// BucketInfo is the definition bucket_info of bucket_api
+type BucketInfo struct {
+	BucketType string `json:"bucket_type,omitempty"`
+	Data       struct {
+		AvailableForUpload BigInt `json:"available_for_upload"`
+		Free               BigInt `json:"free"`
+		LastUpdate         int64  `json:"last_update"`
+		Size               BigInt `json:"size"`
+		SizeReduced        BigInt `json:"size_reduced"`
+	} `json:"data"`
+	Mode       string `json:"mode,omitempty"`
+	Name       string `json:"name"`
+	NumObjects struct {
+		LastUpdate int64 `json:"last_update"`
+		Value      int64 `json:"value"`
+	} `json:"num_objects"`
+	Storage struct {
+		LastUpdate int64       `json:"last_update"`
+		Values     StorageInfo `json:"values"`
+	} `json:"storage"`
+}
+
+// CreateBucketParams is the params of bucket_api.create_bucket()
+type CreateBucketParams struct {
+	Name string `json:"name"`
+}
+
+// CreateBucketReply is the reply of bucket_api.create_bucket()
+type CreateBucketReply struct{}
+
+// CreateBucketAPI calls bucket_api.create_bucket()
+func (c *RPCClient) CreateBucketAPI(params CreateBucketParams) (CreateBucketReply, error) {
+	req := RPCRequest{API: "bucket_api", Method: "create_bucket", Params: params}
+	res := struct {
+		RPCResponse `json:",inline"`
+		Reply       CreateBucketReply `json:"reply"`
+	}{}
+	err := c.Call(req, &res)
+	return res.Reply, err
+}
+
+// DeleteBucketParams is the params of bucket_api.delete_bucket()
+type DeleteBucketParams struct {
+	Name string `json:"name"`
+}
+
+// DeleteBucketReply is the reply of bucket_api.delete_bucket()
+type DeleteBucketReply struct{}
+
+// DeleteBucketAPI calls bucket_api.delete_bucket()
+func (c *RPCClient) DeleteBucketAPI(params DeleteBucketParams) (DeleteBucketReply, error) {
+	req := RPCRequest{API: "bucket_api", Method: "delete_bucket", Params: params}
+	res := struct {
+		RPCResponse `json:",inline"`
+		Reply       DeleteBucketReply `json:"reply"`
+	}{}
+	err := c.Call(req, &res)
+	return res.Reply, err
+}
+
+// DeleteBucketAndObjectsParams is the params of bucket_api.delete_bucket_and_objects()
+type DeleteBucketAndObjectsParams struct {
+	Name string `json:"name"`
+}
+
+// DeleteBucketAndObjectsReply is the reply of bucket_api.delete_bucket_and_objects()
+type DeleteBucketAndObjectsReply struct{}
+
+// DeleteBucketAndObjectsAPI calls bucket_api.delete_bucket_and_objects()
+func (c *RPCClient) DeleteBucketAndObjectsAPI(params DeleteBucketAndObjectsParams) (DeleteBucketAndObjectsReply, error) {
+	req := RPCRequest{API: "bucket_api", Method: "delete_bucket_and_objects", Params: params}
+	res := struct {
+		RPCResponse `json:",inline"`
+		Reply       DeleteBucketAndObjectsReply `json:"reply"`
+	}{}
+	err := c.Call(req, &res)
+	return res.Reply, err
+}
+
+// ListBucketsReply is the reply of bucket_api.list_buckets()
+type ListBucketsReply struct {
+	Buckets []struct {
+		Name string `json:"name"`
+	} `json:"buckets"`
+}
+
+// ListBucketsAPI calls bucket_api.list_buckets()
+func (c *RPCClient) ListBucketsAPI() (ListBucketsReply, error) {
+	req := RPCRequest{API: "bucket_api", Method: "list_buckets"}
+	res := struct {
+		RPCResponse `json:",inline"`
+		Reply       ListBucketsReply `json:"reply"`
+	}{}
+	err := c.Call(req, &res)
+	return res.Reply, err
+}
+
+////////////
+// COMMON //
+////////////
+
+// S3AccessKeys is the definition s3_access_keys of common_api
+type S3AccessKeys struct {
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+}
+
+// StorageInfo is the definition storage_info of common_api
+type StorageInfo struct {
+	Free            BigInt `json:"free"`
+	Real            BigInt `json:"real"`
+	Reserved        BigInt `json:"reserved"`
+	Total           BigInt `json:"total"`
+	UnavailableFree BigInt `json:"unavailable_free"`
+	Used            BigInt `json:"used"`
+	UsedOther       BigInt `json:"used_other"`
+}
+
+//////////
+// POOL //
+//////////
+
+// PoolExtendedInfo is the definition pool_extended_info of pool_api
+type PoolExtendedInfo struct {
+	Mode         string      `json:"mode,omitempty"`
+	Name         string      `json:"name"`
+	ResourceType string      `json:"resource_type,omitempty"`
+	Storage      StorageInfo `json:"storage"`
+}
+
+////////////
+// SYSTEM //
+////////////
+
+// CreateSystemParams is the params of system_api.create_system()
+type CreateSystemParams struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// CreateSystemReply is the reply of system_api.create_system()
+type CreateSystemReply struct {
+	OperatorToken string `json:"operator_token,omitempty"`
+	Token         string `json:"token"`
+}
+
+// CreateSystemAPI calls system_api.create_system()
+func (c *RPCClient) CreateSystemAPI(params CreateSystemParams) (CreateSystemReply, error) {
+	req := RPCRequest{API: "system_api", Method: "create_system", Params: params}
+	res := struct {
+		RPCResponse `json:",inline"`
+		Reply       CreateSystemReply `json:"reply"`
+	}{}
+	err := c.Call(req, &res)
+	return res.Reply, err
+}
+
+// ReadSystemReply is the reply of system_api.read_system()
+type ReadSystemReply struct {
+	Accounts []AccountInfo      `json:"accounts,omitempty"`
+	Buckets  []BucketInfo       `json:"buckets,omitempty"`
+	Name     string             `json:"name"`
+	Objects  int64              `json:"objects"`
+	Pools    []PoolExtendedInfo `json:"pools,omitempty"`
+	Storage  StorageInfo        `json:"storage"`
+	Version  string             `json:"version,omitempty"`
+}
+
+// ReadSystemAPI calls system_api.read_system()
+func (c *RPCClient) ReadSystemAPI() (ReadSystemReply, error) {
+	req := RPCRequest{API: "system_api", Method: "read_system"}
+	res := struct {
+		RPCResponse `json:",inline"`
+		Reply       ReadSystemReply `json:"reply"`
+	}{}
+	err := c.Call(req, &res)
+	return res.Reply, err
+}
@@ -0,0 +1,76 @@
+package system
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// RetryBackoffBase is the requeue delay after the first temporary error of a system
+	RetryBackoffBase = 2 * time.Second
+
+	// RetryBackoffMax limits the requeue delay after consecutive temporary errors
+	RetryBackoffMax = 5 * time.Minute
+
+	// PersistentErrorResync is the requeue delay after a persistent error.
+	// A persistent error requires a change to the spec or to the environment, and changes to the spec
+	// and to the watched objects trigger a reconcile immediately, but other changes in the cluster
+	// (for example the creation of a missing image pull secret) are only noticed by this slow resync.
+	PersistentErrorResync = 30 * time.Minute
+)
+
+// retryFailures counts the consecutive temporary errors per system
+var retryFailures = map[types.NamespacedName]int{}
+var retryFailuresLock sync.Mutex
+
+// NextRetryDelay returns the requeue delay after a failed reconcile. Temporary errors are retried
+// with exponential backoff per system (with jitter to spread the retries of several systems),
+// and persistent errors are retried with the slow PersistentErrorResync.
+func (s *System) NextRetryDelay(err error) time.Duration {
+	if IsPersistentError(err) {
+		s.ResetRetryBackoff()
+		return PersistentErrorResync
+	}
+
+	retryFailuresLock.Lock()
+	failures := retryFailures[s.Request]
+	retryFailures[s.Request] = failures + 1
+	retryFailuresLock.Unlock()
+
+	delay := RetryBackoffBase
+	for i := 0; i < failures && delay < RetryBackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > RetryBackoffMax {
+		delay = RetryBackoffMax
+	}
+	// equal jitter - between half and the full delay
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// ResetRetryBackoff resets the backoff of the system after a successful reconcile
+func (s *System) ResetRetryBackoff() {
+	retryFailuresLock.Lock()
+	defer retryFailuresLock.Unlock()
+	delete(retryFailures, s.Request)
+}
+
+// SetNextRetry computes the requeue delay from the reconcile error and records the next retry
+// time in the status, so that the status update reports when the operator will retry.
+// Returns zero delay when the reconcile succeeded.
+func (s *System) SetNextRetry(err error) time.Duration {
+	if err == nil {
+		s.ResetRetryBackoff()
+		s.NooBaa.Status.NextRetryTime = nil
+		return 0
+	}
+	delay := s.NextRetryDelay(err)
+	next := metav1.NewTime(time.Now().Add(delay))
+	s.NooBaa.Status.NextRetryTime = &next
+	return delay
+}
@@ -0,0 +1,81 @@
+package system
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// newTestBackoffSystem returns a system with its own backoff state (which is kept per system request)
+func newTestBackoffSystem(name string) *System {
+	s := newTestSystem()
+	s.Request = types.NamespacedName{Namespace: testNamespace, Name: name}
+	s.ResetRetryBackoff()
+	return s
+}
+
+func TestNextRetryDelayBackoff(t *testing.T) {
+	s := newTestBackoffSystem("backoff")
+	err := fmt.Errorf("temporary")
+	expected := RetryBackoffBase
+	for i := 0; i < 12; i++ {
+		delay := s.NextRetryDelay(err)
+		if delay < expected/2 || delay > expected {
+			t.Errorf("retry %d: expected delay between %s and %s, got %s", i, expected/2, expected, delay)
+		}
+		expected *= 2
+		if expected > RetryBackoffMax {
+			expected = RetryBackoffMax
+		}
+	}
+
+	// a success resets the backoff
+	s.SetNextRetry(nil)
+	if s.NooBaa.Status.NextRetryTime != nil {
+		t.Errorf("expected no next retry time after a success")
+	}
+	if delay := s.NextRetryDelay(err); delay > RetryBackoffBase {
+		t.Errorf("expected the backoff to be reset, got %s", delay)
+	}
+}
+
+func TestNextRetryDelayPersistent(t *testing.T) {
+	s := newTestBackoffSystem("persistent")
+	for i := 0; i < 5; i++ {
+		s.NextRetryDelay(fmt.Errorf("temporary"))
+	}
+	if delay := s.NextRetryDelay(NewPersistentError(fmt.Errorf("persistent"))); delay != PersistentErrorResync {
+		t.Errorf("expected the persistent resync %s, got %s", PersistentErrorResync, delay)
+	}
+	// a persistent error resets the backoff of temporary errors
+	if delay := s.NextRetryDelay(fmt.Errorf("temporary")); delay > RetryBackoffBase {
+		t.Errorf("expected the backoff to be reset, got %s", delay)
+	}
+}
+
+func TestNextRetryDelayPerSystem(t *testing.T) {
+	s1 := newTestBackoffSystem("system1")
+	s2 := newTestBackoffSystem("system2")
+	for i := 0; i < 5; i++ {
+		s1.NextRetryDelay(fmt.Errorf("temporary"))
+	}
+	if delay := s2.NextRetryDelay(fmt.Errorf("temporary")); delay > RetryBackoffBase {
+		t.Errorf("expected the backoff of another system to be independent, got %s", delay)
+	}
+}
+
+func TestSetNextRetry(t *testing.T) {
+	s := newTestBackoffSystem("next-retry")
+	before := time.Now()
+	delay := s.SetNextRetry(fmt.Errorf("temporary"))
+	next := s.NooBaa.Status.NextRetryTime
+	if next == nil {
+		t.Fatalf("expected the next retry time to be set")
+	}
+	// the status time is serialized with a resolution of seconds
+	if next.Time.Before(before.Add(delay).Add(-time.Second)) || next.Time.After(time.Now().Add(delay).Add(time.Second)) {
+		t.Errorf("expected the next retry time to be in %s, got %s", delay, next.Time)
+	}
+}
@@ -0,0 +1,107 @@
+package system
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/noobaa/noobaa-operator/version"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// DefaultDBBackupMaxBackups is the number of backup files to keep when DBBackupSpec.MaxBackups is not set
+	DefaultDBBackupMaxBackups = 7
+
+	// MongoBinDir is the path of the mongodb tools in the mongodb container
+	MongoBinDir = "/opt/rh/rh-mongodb36/root/usr/bin/"
+
+	// AnnotationRestoringDB on the noobaa system holds the server from starting
+	// while the db is restored from a backup (see noobaa system restore).
+	AnnotationRestoringDB = "noobaa.io/restoring-db"
+)
+
+// restoreHoldCommand replaces the command of the server container while the db is restored,
+// so that the server does not initialize a new system in the empty db.
+var restoreHoldCommand = []string{"/bin/bash", "-c", "echo Waiting for the db restore; sleep infinity"}
+
+// IsRestoringDB returns true while the db of the system is being restored from a backup
+func (s *System) IsRestoringDB() bool {
+	_, restoring := s.NooBaa.Annotations[AnnotationRestoringDB]
+	return restoring
+}
+
+// SetDesiredRestoringDB holds the server container of the CoreApp while the db is restored.
+// The bundle does not set a command for the server, so when the restore is done
+// the command that was merged from the live object is removed and the pod rolls to start the server.
+func (s *System) SetDesiredRestoringDB() {
+	podSpec := &s.CoreApp.Spec.Template.Spec
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		if c.Name != "noobaa-server" {
+			continue
+		}
+		if s.IsRestoringDB() {
+			c.Command = restoreHoldCommand
+		} else {
+			c.Command = nil
+		}
+	}
+}
+
+// ReconcileDBBackup reconciles the CronJob of the scheduled db backups,
+// or deletes it if the backups are not requested in the spec.
+func (s *System) ReconcileDBBackup() error {
+	if s.NooBaa.Spec.DBBackup == nil {
+		return s.DeleteObjectIfExists(s.DBBackupCronJob)
+	}
+	return s.ReconcileObject(s.DBBackupCronJob, s.SetDesiredDBBackup)
+}
+
+// SetDesiredDBBackup updates the DBBackupCronJob as desired for reconciling
+func (s *System) SetDesiredDBBackup() {
+	spec := s.NooBaa.Spec.DBBackup
+	maxBackups := spec.MaxBackups
+	if maxBackups <= 0 {
+		maxBackups = DefaultDBBackupMaxBackups
+	}
+
+	s.DBBackupCronJob.Spec.Schedule = spec.Schedule
+	podSpec := &s.DBBackupCronJob.Spec.JobTemplate.Spec.Template.Spec
+	s.SetDesiredPodScheduling(podSpec)
+	for i := range podSpec.Volumes {
+		if podSpec.Volumes[i].PersistentVolumeClaim != nil {
+			podSpec.Volumes[i].PersistentVolumeClaim.ClaimName = spec.VolumeClaimName
+		}
+	}
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		c.Image = s.OperatorImage()
+		c.Args = []string{
+			"system", "backup",
+			"--namespace", s.Request.Namespace,
+			"--system-name", s.Request.Name,
+			"--output", "/backups",
+			"--max-backups", fmt.Sprint(maxBackups),
+		}
+	}
+	if s.NooBaa.Spec.ImagePullSecret == nil {
+		podSpec.ImagePullSecrets = []corev1.LocalObjectReference{}
+	} else {
+		podSpec.ImagePullSecrets = []corev1.LocalObjectReference{*s.NooBaa.Spec.ImagePullSecret}
+	}
+}
+
+// OperatorImage returns the image of the running operator pod (by the POD_NAME env),
+// or the default operator image of this version when running outside of a pod.
+func (s *System) OperatorImage() string {
+	podName := os.Getenv("POD_NAME")
+	if podName != "" {
+		pod := &corev1.Pod{}
+		err := s.GetObject(podName, pod)
+		if err == nil && len(pod.Spec.Containers) > 0 {
+			return pod.Spec.Containers[0].Image
+		}
+	}
+	return "noobaa/noobaa-operator:" + version.Version
+}
@@ -0,0 +1,75 @@
+package system
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// deleteCountingClient counts the delete requests that are sent to the server
+type deleteCountingClient struct {
+	client.Client
+	Deletes int
+}
+
+func (c *deleteCountingClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOptionFunc) error {
+	c.Deletes++
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func TestReconcileDBBackupDisabled(t *testing.T) {
+	nooBaa := &nbv1.NooBaa{}
+	nooBaa.Namespace = testNamespace
+	nooBaa.Name = testName
+	s := newTestSystem(nooBaa)
+	s.NooBaa = nooBaa
+	c := &deleteCountingClient{Client: s.Client}
+	s.Client = c
+
+	if err := s.ReconcileDBBackup(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Deletes != 0 {
+		t.Errorf("expected no delete request when the cronjob does not exist, got %d", c.Deletes)
+	}
+
+	cronJob := s.DBBackupCronJob.DeepCopy()
+	if err := c.Client.Create(s.Ctx, cronJob); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReconcileDBBackup(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Deletes != 1 {
+		t.Errorf("expected the existing cronjob to be deleted, got %d delete requests", c.Deletes)
+	}
+	if err := s.GetObject(cronJob.Name, cronJob); err == nil {
+		t.Errorf("expected the cronjob to be deleted")
+	}
+}
+
+func TestSetDesiredRestoringDB(t *testing.T) {
+	s := newTestSystem()
+	s.NooBaa.Annotations = map[string]string{AnnotationRestoringDB: "true"}
+	s.SetDesiredRestoringDB()
+	server := s.CoreApp.Spec.Template.Spec.Containers[1]
+	if server.Name != "noobaa-server" || len(server.Command) == 0 {
+		t.Errorf("expected the server container to be held while restoring, got %+v", server)
+	}
+	mongo := s.CoreApp.Spec.Template.Spec.Containers[0]
+	if mongo.Name != "mongodb" || reflect.DeepEqual(mongo.Command, restoreHoldCommand) {
+		t.Errorf("expected the mongodb container to run while restoring, got %+v", mongo)
+	}
+
+	// the live command is merged into the desired object, and should be removed when the restore is done
+	delete(s.NooBaa.Annotations, AnnotationRestoringDB)
+	s.SetDesiredRestoringDB()
+	if cmd := s.CoreApp.Spec.Template.Spec.Containers[1].Command; cmd != nil {
+		t.Errorf("expected the server command to be removed after the restore, got %v", cmd)
+	}
+}
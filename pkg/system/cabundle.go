@@ -0,0 +1,159 @@
+package system
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"sort"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// CABundleKey is the key of the combined CA bundle in the CABundle config map
+	CABundleKey = "ca-bundle.crt"
+
+	// CABundleMountPath is where the CABundle config map is mounted in the server container
+	CABundleMountPath = "/etc/noobaa-ca-bundle"
+
+	// CABundleHashAnnotation on the core pod template rolls the pod when the CA bundle changes,
+	// since the server reads the extra CA certificates only on startup.
+	CABundleHashAnnotation = "noobaa.io/ca-bundle-hash"
+)
+
+// ReconcileCABundle combines the CA certificates of the system CABundle and the CABundle
+// of every backing store of the system into the CABundle config map of the system,
+// which is mounted to the server container by SetDesiredCABundleMount.
+// The config map is deleted when there are no certificates to trust.
+func (s *System) ReconcileCABundle() error {
+
+	log := s.Logger.WithField("func", "ReconcileCABundle")
+
+	var combined bytes.Buffer
+
+	if s.NooBaa.Spec.CABundle != nil {
+		cm := &corev1.ConfigMap{}
+		if err := s.GetObject(s.NooBaa.Spec.CABundle.Name, cm); err != nil {
+			if errors.IsNotFound(err) && s.Recorder != nil {
+				s.Recorder.Eventf(s.NooBaa, corev1.EventTypeWarning, "MissingCABundle",
+					`Waiting for CA bundle config map "%s"`, s.NooBaa.Spec.CABundle.Name)
+			}
+			return err
+		}
+		s.appendCABundle(&combined, "noobaa/"+s.Request.Name, cm)
+	}
+
+	backingStores := &nbv1.BackingStoreList{}
+	err := s.Client.List(s.Ctx, &client.ListOptions{Namespace: s.Request.Namespace}, backingStores)
+	if err != nil {
+		return err
+	}
+	sort.Slice(backingStores.Items, func(i, j int) bool {
+		return backingStores.Items[i].Name < backingStores.Items[j].Name
+	})
+	for i := range backingStores.Items {
+		bs := &backingStores.Items[i]
+		if bs.Spec.CABundle == nil {
+			continue
+		}
+		cm := &corev1.ConfigMap{}
+		if err := s.GetObject(bs.Spec.CABundle.Name, cm); err != nil {
+			// a missing config map of one backing store should not block the system
+			log.Warnf("Failed getting CA bundle config map %s of backing store %s: %s", bs.Spec.CABundle.Name, bs.Name, err)
+			if s.Recorder != nil {
+				s.Recorder.Eventf(s.NooBaa, corev1.EventTypeWarning, "MissingCABundle",
+					`Failed getting CA bundle config map "%s" of backing store "%s": %s`, bs.Spec.CABundle.Name, bs.Name, err)
+			}
+			continue
+		}
+		s.appendCABundle(&combined, "backingstore/"+bs.Name, cm)
+	}
+
+	if combined.Len() == 0 {
+		s.CABundleHash = ""
+		return s.DeleteObjectIfExists(s.CABundle)
+	}
+
+	bundle := combined.String()
+	sum := sha256.Sum256(combined.Bytes())
+	s.CABundleHash = hex.EncodeToString(sum[:8])
+	return s.ReconcileObject(s.CABundle, func() {
+		s.CABundle.Data = map[string]string{CABundleKey: bundle}
+	})
+}
+
+// appendCABundle appends the valid PEM certificates from all the keys of a config map.
+// Keys without valid certificates are skipped with a warning event.
+func (s *System) appendCABundle(combined *bytes.Buffer, source string, cm *corev1.ConfigMap) {
+	keys := []string{}
+	for key := range cm.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		certs := 0
+		rest := []byte(cm.Data[key])
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+				continue
+			}
+			if certs == 0 {
+				fmt.Fprintf(combined, "# %s configmap/%s key %s\n", source, cm.Name, key)
+			}
+			combined.Write(pem.EncodeToMemory(block))
+			certs++
+		}
+		if certs == 0 && s.Recorder != nil {
+			s.Recorder.Eventf(s.NooBaa, corev1.EventTypeWarning, "BadCABundle",
+				`No valid PEM certificates in key "%s" of CA bundle config map "%s" (%s)`, key, cm.Name, source)
+		}
+	}
+}
+
+// SetDesiredCABundleMount mounts the CABundle config map to the server container
+// and adds the certificates to the trust store of the server
+func (s *System) SetDesiredCABundleMount() {
+	if s.CABundleHash == "" {
+		return
+	}
+	podTemplate := &s.CoreApp.Spec.Template
+	if podTemplate.Annotations == nil {
+		podTemplate.Annotations = map[string]string{}
+	}
+	podTemplate.Annotations[CABundleHashAnnotation] = s.CABundleHash
+	setVolume(&podTemplate.Spec, corev1.Volume{
+		Name: "ca-bundle",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: s.CABundle.Name},
+			},
+		},
+	})
+	for i := range podTemplate.Spec.Containers {
+		c := &podTemplate.Spec.Containers[i]
+		if c.Name != "noobaa-server" {
+			continue
+		}
+		setEnv(c, corev1.EnvVar{Name: "NODE_EXTRA_CA_CERTS", Value: CABundleMountPath + "/" + CABundleKey})
+		setVolumeMount(c, corev1.VolumeMount{
+			Name:      "ca-bundle",
+			MountPath: CABundleMountPath,
+			ReadOnly:  true,
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package system
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestReconcileCABundleEmpty(t *testing.T) {
+	s := newTestSystem()
+	c := &deleteCountingClient{Client: s.Client}
+	s.Client = c
+
+	if err := s.ReconcileCABundle(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Deletes != 0 {
+		t.Errorf("expected no delete request when the config map does not exist, got %d", c.Deletes)
+	}
+
+	cm := s.CABundle.DeepCopy()
+	if err := c.Client.Create(s.Ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReconcileCABundle(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Deletes != 1 {
+		t.Errorf("expected the stale config map to be deleted, got %d delete requests", c.Deletes)
+	}
+	if err := s.GetObject(cm.Name, &corev1.ConfigMap{}); err == nil {
+		t.Errorf("expected the config map to be deleted")
+	}
+	if s.CABundleHash != "" {
+		t.Errorf("expected no CA bundle hash, got %s", s.CABundleHash)
+	}
+}
@@ -0,0 +1,164 @@
+package system
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+	"github.com/noobaa/noobaa-operator/pkg/nb"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Finalizer is set on the noobaa system to run the cleanup policy before the system is released
+	Finalizer = "noobaa.io/cleanup"
+
+	// CleanupTimeout limits how long the DeleteAll cleanup waits for the buckets data to be deleted
+	CleanupTimeout = 10 * time.Minute
+
+	// PoolResourceTypeCloud is the pool resource type that core reports for pools of cloud backing stores
+	PoolResourceTypeCloud = "CLOUD"
+)
+
+// ReconcileFinalizer adds the cleanup finalizer to the system if missing
+func (s *System) ReconcileFinalizer() error {
+	for _, f := range s.NooBaa.Finalizers {
+		if f == Finalizer {
+			return nil
+		}
+	}
+	s.NooBaa.Finalizers = append(s.NooBaa.Finalizers, Finalizer)
+	return s.Client.Update(s.Ctx, s.NooBaa)
+}
+
+// ReconcileDeletion runs the cleanup policy of a deleted system and then removes the finalizer
+// to release the system, and the owned resources are then deleted by garbage collection.
+func (s *System) ReconcileDeletion() error {
+
+	log := s.Logger.WithField("func", "ReconcileDeletion")
+
+	finalizers := []string{}
+	for _, f := range s.NooBaa.Finalizers {
+		if f != Finalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	if len(finalizers) == len(s.NooBaa.Finalizers) {
+		return nil
+	}
+
+	s.SetPhase(nbv1.SystemPhaseDeleting)
+	if err := s.LoadObjects(); err != nil {
+		return err
+	}
+
+	// the data is kept unless a policy to delete it was set explicitly
+	policy := s.NooBaa.Spec.CleanupPolicy
+	if policy == "" {
+		policy = nbv1.CleanupPolicyRetain
+	}
+	log.Infof("Running cleanup policy %s", policy)
+
+	if policy == nbv1.CleanupPolicyDeleteAll {
+		err := s.CleanupBuckets()
+		if err != nil {
+			if time.Since(s.NooBaa.DeletionTimestamp.Time) < CleanupTimeout {
+				return err
+			}
+			log.Warnf("Cleanup of buckets timed out, continue deletion: %s", err)
+			if s.Recorder != nil {
+				s.Recorder.Eventf(s.NooBaa, corev1.EventTypeWarning, "CleanupTimeout",
+					`Cleanup of buckets did not complete in %s, some data might remain in the backing stores: %s`,
+					CleanupTimeout, err)
+			}
+		}
+	}
+
+	if policy != nbv1.CleanupPolicyRetain {
+		if err := s.CleanupPVCs(); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("✅ Cleanup policy %s done, removing finalizer", policy)
+	s.NooBaa.Finalizers = finalizers
+	return s.Client.Update(s.Ctx, s.NooBaa)
+}
+
+// CleanupBuckets deletes all the NooBaa buckets of the system with their objects,
+// and waits for the system to delete the data from the cloud pools (the target buckets of the backing stores).
+// The target buckets themselves are not deleted.
+func (s *System) CleanupBuckets() error {
+
+	log := s.Logger.WithField("func", "CleanupBuckets")
+
+	if s.SecretOp.StringData["auth_token"] == "" {
+		log.Infof("System was not created in the server, no buckets to delete")
+		return nil
+	}
+	if err := s.InitNooBaaClient(); err != nil {
+		return err
+	}
+	sys, err := s.NBClient.ReadSystemAPI()
+	if err != nil {
+		return err
+	}
+
+	for i := range sys.Buckets {
+		name := sys.Buckets[i].Name
+		log.Infof("Deleting bucket %s with its objects", name)
+		_, err := s.NBClient.DeleteBucketAndObjectsAPI(nb.DeleteBucketAndObjectsParams{Name: name})
+		if err != nil {
+			log.Warnf("Failed deleting bucket %s: %s", name, err)
+		}
+	}
+	if len(sys.Buckets) > 0 {
+		return fmt.Errorf("waiting for %d buckets to be deleted", len(sys.Buckets))
+	}
+
+	for i := range sys.Pools {
+		pool := &sys.Pools[i]
+		if pool.ResourceType == PoolResourceTypeCloud && pool.Storage.Used.Float64() > 0 {
+			return fmt.Errorf("waiting for the data of pool %s to be deleted (%.0f bytes used)",
+				pool.Name, pool.Storage.Used.Float64())
+		}
+	}
+	return nil
+}
+
+// CleanupPVCs deletes the PVCs that were created from the volume claim templates of the core statefulset
+func (s *System) CleanupPVCs() error {
+
+	log := s.Logger.WithField("func", "CleanupPVCs")
+
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	err := s.Client.List(s.Ctx, &client.ListOptions{Namespace: s.Request.Namespace}, pvcs)
+	if err != nil {
+		return err
+	}
+
+	// the statefulset names the pvc of every replica as <template>-<statefulset>-<ordinal>
+	for i := range s.CoreApp.Spec.VolumeClaimTemplates {
+		prefix := s.CoreApp.Spec.VolumeClaimTemplates[i].Name + "-" + s.CoreApp.Name + "-"
+		for j := range pvcs.Items {
+			pvc := &pvcs.Items[j]
+			if !strings.HasPrefix(pvc.Name, prefix) || pvc.DeletionTimestamp != nil {
+				continue
+			}
+			if _, err := strconv.Atoi(strings.TrimPrefix(pvc.Name, prefix)); err != nil {
+				continue
+			}
+			log.Infof("Deleting PVC %s", pvc.Name)
+			err := s.Client.Delete(s.Ctx, pvc)
+			if err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
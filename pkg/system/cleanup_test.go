@@ -0,0 +1,58 @@
+package system
+
+import (
+	"testing"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// TestReconcileDeletionPolicy checks that the PVCs of a deleted system are kept by default,
+// and deleted only when the policy is set explicitly
+func TestReconcileDeletionPolicy(t *testing.T) {
+	tests := []struct {
+		policy  nbv1.CleanupPolicy
+		deleted bool
+	}{
+		{"", false},
+		{nbv1.CleanupPolicyRetain, false},
+		{nbv1.CleanupPolicyDeletePVCs, true},
+	}
+	for _, test := range tests {
+		now := metav1.Now()
+		nooBaa := &nbv1.NooBaa{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         testNamespace,
+				Name:              testName,
+				Finalizers:        []string{Finalizer},
+				DeletionTimestamp: &now,
+			},
+			Spec: nbv1.NooBaaSpec{CleanupPolicy: test.policy},
+		}
+		s := newTestSystem()
+		pvcName := s.CoreApp.Spec.VolumeClaimTemplates[0].Name + "-" + s.CoreApp.Name + "-0"
+		pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: pvcName}}
+		s = newTestSystem(nooBaa, s.CoreApp, pvc)
+		if err := s.Client.Get(s.Ctx, s.Request, s.NooBaa); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.ReconcileDeletion(); err != nil {
+			t.Fatalf("policy %q: reconcile deletion failed: %s", test.policy, err)
+		}
+		if len(s.NooBaa.Finalizers) != 0 {
+			t.Errorf("policy %q: expected the finalizer to be removed, got %v", test.policy, s.NooBaa.Finalizers)
+		}
+		err := s.Client.Get(s.Ctx, types.NamespacedName{Namespace: testNamespace, Name: pvcName}, &corev1.PersistentVolumeClaim{})
+		if test.deleted && !errors.IsNotFound(err) {
+			t.Errorf("policy %q: expected the PVC to be deleted, got %v", test.policy, err)
+		}
+		if !test.deleted && err != nil {
+			t.Errorf("policy %q: expected the PVC to be kept, got %v", test.policy, err)
+		}
+	}
+}
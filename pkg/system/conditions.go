@@ -0,0 +1,83 @@
+package system
+
+import (
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// SetStandardConditions sets the Available, Progressing and Degraded conditions from the result of the reconcile.
+// A nil error means the system is ready, a temporary error means the reconcile is still progressing,
+// and a persistent error means the system is degraded until the spec or the environment is changed.
+// The Available condition reflects whether the system is running and the operator is connected to it,
+// so a system that was available stays available while changes are applied (for example a spec change).
+// It is Unknown when the operator cannot tell - on the first reconcile, and when the connection to the server is lost.
+func (s *System) SetStandardConditions(err error) {
+
+	phase := s.NooBaa.Status.Phase
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	available := s.getCondition(nbv1.ConditionTypeAvailable)
+
+	switch {
+	case err == nil:
+		s.SetCondition(nbv1.ConditionTypeAvailable, nbv1.ConditionTrue, "SystemReady", "The system is ready")
+	case phase == nbv1.SystemPhaseDeleting:
+		s.SetCondition(nbv1.ConditionTypeAvailable, nbv1.ConditionFalse, "Deleting", "The system is being deleted")
+	case s.getCondition(nbv1.ConditionTypeCredentialsLost) == nbv1.ConditionTrue:
+		s.SetCondition(nbv1.ConditionTypeAvailable, nbv1.ConditionFalse, "CredentialsLost", "The operator cannot authenticate to the system")
+	case available == "" && phase == nbv1.SystemPhaseRejected:
+		s.SetCondition(nbv1.ConditionTypeAvailable, nbv1.ConditionFalse, "SpecRejected", "The system spec is rejected")
+	case available == "":
+		s.SetCondition(nbv1.ConditionTypeAvailable, nbv1.ConditionUnknown, string(phase), "The system is being created")
+	case !s.isCoreAppReady():
+		s.SetCondition(nbv1.ConditionTypeAvailable, nbv1.ConditionFalse, "CorePodsNotReady", "The core pods are not ready")
+	case available != nbv1.ConditionFalse && s.ConnectErr != nil:
+		// the system might be available to its clients, but the operator cannot tell
+		s.SetCondition(nbv1.ConditionTypeAvailable, nbv1.ConditionUnknown, "NotConnected",
+			"The operator cannot connect to the system: "+s.ConnectErr.Error())
+	case available == nbv1.ConditionTrue:
+		// keep the system available while the reconcile is applying changes
+	case s.Connected && s.NooBaa.Status.Accounts.Admin.SecretRef.Name != "":
+		s.SetCondition(nbv1.ConditionTypeAvailable, nbv1.ConditionTrue, "SystemConnected", "The operator is connected to the system")
+	default:
+		s.SetCondition(nbv1.ConditionTypeAvailable, nbv1.ConditionFalse, string(phase), "The system is not ready yet")
+	}
+
+	switch {
+	case err == nil:
+		s.SetCondition(nbv1.ConditionTypeProgressing, nbv1.ConditionFalse, "ReconcileCompleted", "")
+		s.SetCondition(nbv1.ConditionTypeDegraded, nbv1.ConditionFalse, "AsExpected", "")
+	case IsPersistentError(err):
+		reason := "ReconcileFailed"
+		if phase == nbv1.SystemPhaseRejected {
+			reason = "SpecRejected"
+		}
+		s.SetCondition(nbv1.ConditionTypeProgressing, nbv1.ConditionFalse, reason, message)
+		s.SetCondition(nbv1.ConditionTypeDegraded, nbv1.ConditionTrue, reason, message)
+	default:
+		s.SetCondition(nbv1.ConditionTypeProgressing, nbv1.ConditionTrue, string(phase), message)
+		s.SetCondition(nbv1.ConditionTypeDegraded, nbv1.ConditionFalse, "AsExpected", "")
+	}
+}
+
+// isCoreAppReady returns true if the core statefulset has ready pods
+func (s *System) isCoreAppReady() bool {
+	sts := &appsv1.StatefulSet{}
+	if err := s.GetObject(s.CoreApp.Name, sts); err != nil {
+		return false
+	}
+	return sts.Status.ReadyReplicas > 0
+}
+
+// getCondition returns the status of a condition type, or empty if the condition is not set
+func (s *System) getCondition(condType nbv1.ConditionType) nbv1.ConditionStatus {
+	for i := range s.NooBaa.Status.Conditions {
+		if s.NooBaa.Status.Conditions[i].Type == condType {
+			return s.NooBaa.Status.Conditions[i].Status
+		}
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+package system
+
+import (
+	"fmt"
+	"testing"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+)
+
+// testCondition returns the condition of a type, or nil if it is not set
+func testCondition(s *System, condType nbv1.ConditionType) *nbv1.SystemCondition {
+	for i := range s.NooBaa.Status.Conditions {
+		if s.NooBaa.Status.Conditions[i].Type == condType {
+			return &s.NooBaa.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func TestSetStandardConditionsAvailable(t *testing.T) {
+	tests := []struct {
+		name           string
+		available      nbv1.ConditionStatus
+		credsLost      bool
+		coreReady      bool
+		connectErr     error
+		phase          nbv1.SystemPhase
+		err            error
+		expected       nbv1.ConditionStatus
+		expectedReason string
+	}{{
+		name:           "ready",
+		coreReady:      true,
+		phase:          nbv1.SystemPhaseReady,
+		expected:       nbv1.ConditionTrue,
+		expectedReason: "SystemReady",
+	}, {
+		name:           "first reconcile",
+		phase:          nbv1.SystemPhaseCreating,
+		err:            fmt.Errorf("creating"),
+		expected:       nbv1.ConditionUnknown,
+		expectedReason: string(nbv1.SystemPhaseCreating),
+	}, {
+		name:           "first reconcile rejected",
+		phase:          nbv1.SystemPhaseRejected,
+		err:            NewPersistentError(fmt.Errorf("bad spec")),
+		expected:       nbv1.ConditionFalse,
+		expectedReason: "SpecRejected",
+	}, {
+		name:           "core pods not ready",
+		available:      nbv1.ConditionTrue,
+		phase:          nbv1.SystemPhaseCreating,
+		err:            fmt.Errorf("waiting"),
+		expected:       nbv1.ConditionFalse,
+		expectedReason: "CorePodsNotReady",
+	}, {
+		name:           "applying changes",
+		available:      nbv1.ConditionTrue,
+		coreReady:      true,
+		phase:          nbv1.SystemPhaseCreating,
+		err:            fmt.Errorf("waiting"),
+		expected:       nbv1.ConditionTrue,
+		expectedReason: "SystemReady",
+	}, {
+		name:           "connection lost",
+		available:      nbv1.ConditionTrue,
+		coreReady:      true,
+		connectErr:     fmt.Errorf("connection refused"),
+		phase:          nbv1.SystemPhaseWaitingToConnect,
+		err:            fmt.Errorf("connection refused"),
+		expected:       nbv1.ConditionUnknown,
+		expectedReason: "NotConnected",
+	}, {
+		name:           "credentials lost",
+		available:      nbv1.ConditionTrue,
+		credsLost:      true,
+		coreReady:      true,
+		phase:          nbv1.SystemPhaseConfiguring,
+		err:            NewPersistentError(fmt.Errorf("cannot authenticate")),
+		expected:       nbv1.ConditionFalse,
+		expectedReason: "CredentialsLost",
+	}}
+
+	for _, test := range tests {
+		s := newTestSystem()
+		if test.coreReady {
+			sts := s.CoreApp.DeepCopy()
+			sts.Status.ReadyReplicas = 1
+			if err := s.Client.Create(s.Ctx, sts); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if test.available != "" {
+			s.SetCondition(nbv1.ConditionTypeAvailable, test.available, "SystemReady", "")
+		}
+		if test.credsLost {
+			s.SetCondition(nbv1.ConditionTypeCredentialsLost, nbv1.ConditionTrue, "AuthenticationFailed", "")
+		}
+		s.ConnectErr = test.connectErr
+		s.NooBaa.Status.Phase = test.phase
+
+		s.SetStandardConditions(test.err)
+		c := testCondition(s, nbv1.ConditionTypeAvailable)
+		if c == nil || c.Status != test.expected || c.Reason != test.expectedReason {
+			t.Errorf("%s: expected Available %s (%s), got %+v", test.name, test.expected, test.expectedReason, c)
+		}
+	}
+}
+
+func TestSetStandardConditionsDegraded(t *testing.T) {
+	s := newTestSystem()
+	s.NooBaa.Status.Phase = nbv1.SystemPhaseRejected
+	s.SetStandardConditions(NewPersistentError(fmt.Errorf("bad spec")))
+	if c := testCondition(s, nbv1.ConditionTypeDegraded); c == nil || c.Status != nbv1.ConditionTrue || c.Reason != "SpecRejected" {
+		t.Errorf("expected Degraded for a rejected spec, got %+v", c)
+	}
+	if c := testCondition(s, nbv1.ConditionTypeProgressing); c == nil || c.Status != nbv1.ConditionFalse {
+		t.Errorf("expected not Progressing for a rejected spec, got %+v", c)
+	}
+
+	s.NooBaa.Status.Phase = nbv1.SystemPhaseCreating
+	s.SetStandardConditions(fmt.Errorf("waiting"))
+	if c := testCondition(s, nbv1.ConditionTypeDegraded); c == nil || c.Status != nbv1.ConditionFalse {
+		t.Errorf("expected not Degraded for a temporary error, got %+v", c)
+	}
+	if c := testCondition(s, nbv1.ConditionTypeProgressing); c == nil || c.Status != nbv1.ConditionTrue || c.Reason != string(nbv1.SystemPhaseCreating) {
+		t.Errorf("expected Progressing for a temporary error, got %+v", c)
+	}
+}
@@ -0,0 +1,286 @@
+package system
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// mergeDesiredState renders the desired state of an object on top of the live object.
+// The result starts from the desired object (from the bundle template), so fields that were added
+// to the live object by hand (such as a nodeSelector or extra labels and annotations) are dropped
+// and detected as a drift. Only the fields that are owned by the server are kept from the live object:
+// the server-owned metadata (see serverOwnedMetadataFields), the status,
+// and the fields that the server fills in with defaults when they are not desired (see serverDefaultedFields).
+// Lists of named items (containers, volumes, env, ports) are merged by name,
+// other lists of the same length are merged by index and the rest are replaced.
+// Fields that cannot be updated are copied later by preserveImmutableFields.
+// The result is written to the desired object.
+func mergeDesiredState(desired runtime.Object, live runtime.Object) error {
+	desiredMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(desired)
+	if err != nil {
+		return err
+	}
+	liveMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(live)
+	if err != nil {
+		return err
+	}
+	merged := mergeDesiredValue(liveMap, desiredMap, "").(map[string]interface{})
+	if liveMeta, ok := liveMap["metadata"].(map[string]interface{}); ok {
+		mergedMeta, ok := merged["metadata"].(map[string]interface{})
+		if !ok {
+			mergedMeta = map[string]interface{}{}
+			merged["metadata"] = mergedMeta
+		}
+		for key := range serverOwnedMetadataFields {
+			if value, ok := liveMeta[key]; ok {
+				mergedMeta[key] = value
+			}
+		}
+	}
+	if status, ok := liveMap["status"]; ok {
+		merged["status"] = status
+	}
+	v := reflect.ValueOf(desired).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(merged, desired)
+}
+
+// mergeDesiredValue merges a desired value with the live value of the same field,
+// where key is the name of the field (or "" for list items and the root object)
+func mergeDesiredValue(live interface{}, desired interface{}, key string) interface{} {
+	if isEmptyValue(desired) && serverDefaultedFields[key] && live != nil {
+		return live
+	}
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return d
+		}
+		merged := make(map[string]interface{}, len(d))
+		for key, value := range d {
+			merged[key] = mergeDesiredValue(l[key], value, key)
+		}
+		for key, value := range l {
+			if _, ok := d[key]; !ok && serverDefaultedFields[key] {
+				merged[key] = value
+			}
+		}
+		return merged
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok {
+			return d
+		}
+		if liveByName, named := namedItems(l, d); named {
+			merged := make([]interface{}, len(d))
+			for i := range d {
+				merged[i] = mergeDesiredValue(liveByName[itemName(d[i])], d[i], "")
+			}
+			return merged
+		}
+		if len(l) != len(d) {
+			return d
+		}
+		merged := make([]interface{}, len(d))
+		for i := range d {
+			merged[i] = mergeDesiredValue(l[i], d[i], "")
+		}
+		return merged
+	default:
+		return desired
+	}
+}
+
+// namedItems returns the live items by name when the items of both lists are objects with a name,
+// such as containers, volumes, env vars and ports.
+func namedItems(live []interface{}, desired []interface{}) (map[string]interface{}, bool) {
+	if len(desired) == 0 {
+		return nil, false
+	}
+	for _, item := range desired {
+		if itemName(item) == "" {
+			return nil, false
+		}
+	}
+	byName := make(map[string]interface{}, len(live))
+	for _, item := range live {
+		name := itemName(item)
+		if name == "" {
+			return nil, false
+		}
+		byName[name] = item
+	}
+	return byName, true
+}
+
+func itemName(item interface{}) string {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	name, _ := m["name"].(string)
+	return name
+}
+
+// serverOwnedMetadataFields are the metadata fields that are set by the server (or by the garbage collector)
+// and are kept from the live object. Labels and annotations are not included, so the ones that are not
+// desired are removed (for example a stale hash annotation of the pod template).
+var serverOwnedMetadataFields = map[string]bool{
+	"uid":                        true,
+	"resourceVersion":            true,
+	"generation":                 true,
+	"creationTimestamp":          true,
+	"selfLink":                   true,
+	"deletionTimestamp":          true,
+	"deletionGracePeriodSeconds": true,
+	"finalizers":                 true,
+}
+
+// serverDefaultedFields are fields that the api server fills in with defaults (or assigns) when they are not set,
+// so a field that is not set in the desired object and is set in the live object is not a drift.
+// The merge keeps these fields from the live object, and the diff ignores them for list items
+// that are added by the desired funcs after the merge (such as the volumes of the external db, kms, ca bundle and tls secrets).
+var serverDefaultedFields = map[string]bool{
+	"defaultMode":                   true,
+	"terminationMessagePath":        true,
+	"terminationMessagePolicy":      true,
+	"imagePullPolicy":               true,
+	"protocol":                      true,
+	"restartPolicy":                 true,
+	"dnsPolicy":                     true,
+	"schedulerName":                 true,
+	"terminationGracePeriodSeconds": true,
+	"revisionHistoryLimit":          true,
+	"apiVersion":                    true,
+	"rollingUpdate":                 true,
+	"sessionAffinity":               true,
+	"externalTrafficPolicy":         true,
+	"nodePort":                      true,
+	"targetPort":                    true,
+}
+
+// preserveImmutableFields copies the fields that the server does not allow to update from the live object
+func preserveImmutableFields(desired runtime.Object, live runtime.Object) {
+	switch d := desired.(type) {
+	case *appsv1.StatefulSet:
+		l := live.(*appsv1.StatefulSet)
+		d.Spec.Selector = l.Spec.Selector
+		d.Spec.ServiceName = l.Spec.ServiceName
+		d.Spec.PodManagementPolicy = l.Spec.PodManagementPolicy
+		d.Spec.VolumeClaimTemplates = l.Spec.VolumeClaimTemplates
+	case *corev1.Service:
+		l := live.(*corev1.Service)
+		d.Spec.ClusterIP = l.Spec.ClusterIP
+	}
+}
+
+// diffDesiredState returns the paths of the fields where the desired object differs from the live object.
+// The status is ignored, and empty values (nil, zero, empty list/map) are considered equal to missing fields.
+func diffDesiredState(desired runtime.Object, live runtime.Object) ([]string, error) {
+	desiredMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(desired)
+	if err != nil {
+		return nil, err
+	}
+	liveMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(live)
+	if err != nil {
+		return nil, err
+	}
+	delete(desiredMap, "status")
+	delete(liveMap, "status")
+	diff := []string{}
+	diffValues(desiredMap, liveMap, "", &diff)
+	return diff, nil
+}
+
+func diffValues(desired interface{}, live interface{}, path string, diff *[]string) {
+	if isEmptyValue(desired) && isEmptyValue(live) {
+		return
+	}
+	if isEmptyValue(desired) && serverDefaultedFields[lastPathKey(path)] {
+		return
+	}
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			*diff = append(*diff, path)
+			return
+		}
+		keys := []string{}
+		for key := range d {
+			keys = append(keys, key)
+		}
+		for key := range l {
+			if _, ok := d[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			diffValues(d[key], l[key], keyPath, diff)
+		}
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok {
+			*diff = append(*diff, path)
+			return
+		}
+		if len(l) != len(d) {
+			*diff = append(*diff, path)
+			return
+		}
+		if _, named := namedItems(l, d); named {
+			for i := range d {
+				name := itemName(d[i])
+				if itemName(l[i]) != name {
+					// replaced or reordered items
+					*diff = append(*diff, path)
+					return
+				}
+				diffValues(d[i], l[i], fmt.Sprintf("%s[%s]", path, name), diff)
+			}
+			return
+		}
+		for i := range d {
+			diffValues(d[i], l[i], fmt.Sprintf("%s[%d]", path, i), diff)
+		}
+	default:
+		if !reflect.DeepEqual(desired, live) {
+			*diff = append(*diff, path)
+		}
+	}
+}
+
+// lastPathKey returns the last key of a field path, e.g. "defaultMode" for "spec.volumes[db].secret.defaultMode"
+func lastPathKey(path string) string {
+	return path[strings.LastIndex(path, ".")+1:]
+}
+
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	}
+	return false
+}
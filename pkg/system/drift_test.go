@@ -0,0 +1,223 @@
+package system
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// testStatefulSet returns a statefulset with the given containers and volumes
+func testStatefulSet(containers []corev1.Container, volumes []corev1.Volume) *appsv1.StatefulSet {
+	sts := &appsv1.StatefulSet{}
+	sts.Name = "noobaa-core"
+	sts.Spec.Template.Spec.Containers = containers
+	sts.Spec.Template.Spec.Volumes = volumes
+	return sts
+}
+
+// testLiveStatefulSet returns the statefulset of an external db system as read from the server,
+// with the fields that the server fills in with defaults
+func testLiveStatefulSet() *appsv1.StatefulSet {
+	defaultMode := int32(420)
+	return testStatefulSet(
+		[]corev1.Container{{
+			Name:                     "noobaa-server",
+			Image:                    "noobaa/noobaa-core:5",
+			TerminationMessagePath:   "/dev/termination-log",
+			TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+			ImagePullPolicy:          corev1.PullIfNotPresent,
+			Ports:                    []corev1.ContainerPort{{Name: "mgmt", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}},
+		}},
+		[]corev1.Volume{{
+			Name: "external-db-ca",
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName:  "external-db",
+				DefaultMode: &defaultMode,
+			}},
+		}},
+	)
+}
+
+// testDesiredStatefulSet renders the desired statefulset like ReconcileObject does:
+// the template (with the embedded mongodb container) is merged on top of the live object,
+// and then the desired func removes the mongodb container and adds the external db volume.
+func testDesiredStatefulSet(t *testing.T, live *appsv1.StatefulSet) *appsv1.StatefulSet {
+	desired := testStatefulSet(
+		[]corev1.Container{
+			{Name: "noobaa-server", Image: "NOOBAA_IMAGE", Ports: []corev1.ContainerPort{{Name: "mgmt", ContainerPort: 8080}}},
+			{Name: "mongodb", Image: "MONGO_IMAGE"},
+		},
+		nil,
+	)
+	if err := mergeDesiredState(desired, live.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	podSpec := &desired.Spec.Template.Spec
+	podSpec.Containers = podSpec.Containers[:1]
+	podSpec.Containers[0].Image = "noobaa/noobaa-core:5"
+	podSpec.Volumes = []corev1.Volume{{
+		Name: "external-db-ca",
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+			SecretName: "external-db",
+		}},
+	}}
+	return desired
+}
+
+func TestMergeDesiredStateByName(t *testing.T) {
+	live := testLiveStatefulSet()
+	desired := testDesiredStatefulSet(t, live)
+	c := desired.Spec.Template.Spec.Containers[0]
+	if c.TerminationMessagePath != "/dev/termination-log" ||
+		c.TerminationMessagePolicy != corev1.TerminationMessageReadFile ||
+		c.ImagePullPolicy != corev1.PullIfNotPresent {
+		t.Errorf("expected the server defaults of the container to be merged from the live object, got %+v", c)
+	}
+	if c.Ports[0].Protocol != corev1.ProtocolTCP {
+		t.Errorf("expected the port protocol to be merged from the live object, got %+v", c.Ports[0])
+	}
+}
+
+func TestMergeDesiredStateOverridesLive(t *testing.T) {
+	live := testLiveStatefulSet()
+	desired := testStatefulSet(
+		[]corev1.Container{{Name: "noobaa-server", Image: "noobaa/noobaa-core:5.1"}},
+		nil,
+	)
+	if err := mergeDesiredState(desired, live.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	if desired.Spec.Template.Spec.Containers[0].Image != "noobaa/noobaa-core:5.1" {
+		t.Errorf("expected the desired image, got %s", desired.Spec.Template.Spec.Containers[0].Image)
+	}
+	if len(desired.Spec.Template.Spec.Volumes) != 0 {
+		t.Errorf("expected the live volumes that are not desired to be removed, got %+v", desired.Spec.Template.Spec.Volumes)
+	}
+}
+
+func TestMergeDesiredStateDropsManualFields(t *testing.T) {
+	live := testLiveStatefulSet()
+	live.UID = "test-uid"
+	live.ResourceVersion = "7"
+	live.Spec.Template.Spec.NodeSelector = map[string]string{"disk": "ssd"}
+	live.Spec.Template.Spec.HostNetwork = true
+	live.Spec.Template.Annotations = map[string]string{
+		TLSHashAnnotation: "stale",
+		"manual":          "true",
+	}
+
+	desired := testDesiredStatefulSet(t, live)
+	if desired.UID != live.UID || desired.ResourceVersion != live.ResourceVersion {
+		t.Errorf("expected the server-owned metadata to be kept, got uid=%q resourceVersion=%q", desired.UID, desired.ResourceVersion)
+	}
+	podTemplate := desired.Spec.Template
+	if podTemplate.Spec.NodeSelector != nil || podTemplate.Spec.HostNetwork || podTemplate.Annotations != nil {
+		t.Errorf("expected the manual fields to be removed, got nodeSelector=%v hostNetwork=%v annotations=%v",
+			podTemplate.Spec.NodeSelector, podTemplate.Spec.HostNetwork, podTemplate.Annotations)
+	}
+
+	diff, err := diffDesiredState(desired, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"spec.template.metadata.annotations",
+		"spec.template.spec.hostNetwork",
+		"spec.template.spec.nodeSelector",
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("expected diff %v, got %v", expected, diff)
+	}
+}
+
+func TestMergeDesiredStateService(t *testing.T) {
+	live := &corev1.Service{}
+	live.Name = "noobaa-mgmt"
+	live.Spec.Type = corev1.ServiceTypeNodePort
+	live.Spec.ClusterIP = "10.0.0.1"
+	live.Spec.SessionAffinity = corev1.ServiceAffinityNone
+	live.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+	live.Spec.Ports = []corev1.ServicePort{{
+		Name:       "mgmt",
+		Port:       8080,
+		TargetPort: intstr.FromInt(8080),
+		NodePort:   30080,
+		Protocol:   corev1.ProtocolTCP,
+	}}
+
+	desired := &corev1.Service{}
+	desired.Name = "noobaa-mgmt"
+	desired.Spec.Type = corev1.ServiceTypeNodePort
+	desired.Spec.Ports = []corev1.ServicePort{{Name: "mgmt", Port: 8080}}
+	if err := mergeDesiredState(desired, live.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	preserveImmutableFields(desired, live)
+	if desired.Spec.Ports[0].NodePort != 30080 {
+		t.Errorf("expected the node port to be kept from the live service, got %+v", desired.Spec.Ports[0])
+	}
+	diff, err := diffDesiredState(desired, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 0 {
+		t.Errorf("expected no diff, got %v", diff)
+	}
+}
+
+func TestDiffDesiredStateIgnoresServerDefaults(t *testing.T) {
+	live := testLiveStatefulSet()
+	desired := testDesiredStatefulSet(t, live)
+	diff, err := diffDesiredState(desired, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) != 0 {
+		t.Errorf("expected no diff, got %v", diff)
+	}
+}
+
+func TestDiffDesiredStateChanges(t *testing.T) {
+	live := testLiveStatefulSet()
+
+	desired := testDesiredStatefulSet(t, live)
+	desired.Spec.Template.Spec.Containers[0].Image = "noobaa/noobaa-core:5.1"
+	desired.Spec.Template.Spec.Volumes[0].Secret.SecretName = "other-db"
+	diff, err := diffDesiredState(desired, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"spec.template.spec.containers[noobaa-server].image",
+		"spec.template.spec.volumes[external-db-ca].secret.secretName",
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("expected diff %v, got %v", expected, diff)
+	}
+
+	desired = testDesiredStatefulSet(t, live)
+	desired.Spec.Template.Spec.Volumes = append(desired.Spec.Template.Spec.Volumes, corev1.Volume{Name: "ca-bundle"})
+	diff, err = diffDesiredState(desired, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"spec.template.spec.volumes"}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("expected diff %v, got %v", expected, diff)
+	}
+
+	// a field that is not defaulted by the server is a drift when it is removed
+	desired = testDesiredStatefulSet(t, live)
+	live.Spec.Template.Spec.Containers[0].WorkingDir = "/tmp"
+	diff, err = diffDesiredState(desired, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"spec.template.spec.containers[noobaa-server].workingDir"}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("expected diff %v, got %v", expected, diff)
+	}
+}
@@ -0,0 +1,444 @@
+package system
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// KMSVaultCAMountPath is where the vault CA secret is mounted in the server container
+	KMSVaultCAMountPath = "/etc/noobaa-kms-vault-ca"
+)
+
+// KMSKeyName returns the name of the root master key in the kms provider
+func (s *System) KMSKeyName() string {
+	kms := s.NooBaa.Spec.Security.KMS
+	if kms != nil && kms.KeyName != "" {
+		return kms.KeyName
+	}
+	return s.Request.Name + "-root-master-key"
+}
+
+// KMSSecretName returns the name of the secret of the kubernetes kms provider
+func (s *System) KMSSecretName() string {
+	kms := s.NooBaa.Spec.Security.KMS
+	if kms != nil && kms.Secret != nil && kms.Secret.Name != "" {
+		return kms.Secret.Name
+	}
+	return s.Request.Name + "-root-master-key"
+}
+
+// CheckSpecKMS checks the System.Spec.Security.KMS properties
+func (s *System) CheckSpecKMS() error {
+
+	log := s.Logger.WithField("func", "CheckSpecKMS")
+
+	kms := s.NooBaa.Spec.Security.KMS
+	if kms == nil {
+		return nil
+	}
+
+	reject := func(format string, args ...interface{}) error {
+		msg := fmt.Sprintf(format, args...)
+		log.Errorf("%s", msg)
+		if s.Recorder != nil {
+			s.Recorder.Event(s.NooBaa, corev1.EventTypeWarning, "BadKMSSpec", msg)
+		}
+		s.SetPhase(nbv1.SystemPhaseRejected)
+		return NewPersistentError(fmt.Errorf("%s", msg))
+	}
+
+	switch kms.Provider {
+	case nbv1.KMSProviderKubernetes:
+	case nbv1.KMSProviderVault:
+		v := kms.Vault
+		if v == nil || v.Address == "" || v.Path == "" || v.TokenSecret.Name == "" {
+			return reject(`KMS provider "%s" requires vault address, path and tokenSecret`, kms.Provider)
+		}
+		if !strings.HasPrefix(v.Address, "http://") && !strings.HasPrefix(v.Address, "https://") {
+			return reject(`Invalid vault address "%s"`, v.Address)
+		}
+		if len(strings.Split(strings.Trim(v.Path, "/"), "/")) < 2 {
+			return reject(`Invalid vault path "%s" (expected <mount>/<path>)`, v.Path)
+		}
+		if v.KVVersion != 0 && v.KVVersion != 1 && v.KVVersion != 2 {
+			return reject(`Invalid vault kvVersion %d`, v.KVVersion)
+		}
+	default:
+		return reject(`Invalid KMS provider "%s"`, kms.Provider)
+	}
+	return nil
+}
+
+// ReconcileKMS makes sure the root master key exists in the kms provider,
+// and generates a random key only on the first install of the system (see CanGenerateRootKey).
+// The key is recorded in the status once it exists, and the server is configured with a reference to the key by SetDesiredKMS.
+func (s *System) ReconcileKMS() error {
+	kms := s.NooBaa.Spec.Security.KMS
+	if kms == nil {
+		return nil
+	}
+	switch kms.Provider {
+	case nbv1.KMSProviderKubernetes:
+		return s.ReconcileKMSKubernetes()
+	case nbv1.KMSProviderVault:
+		return s.ReconcileKMSVault()
+	}
+	return nil
+}
+
+// ReconcileKMSKubernetes keeps the root master key in a kubernetes secret.
+// The secret is not owned by the system so that the key is kept when the system is deleted.
+func (s *System) ReconcileKMSKubernetes() error {
+
+	log := s.Logger.WithField("func", "ReconcileKMSKubernetes")
+
+	keyName := s.KMSKeyName()
+	secret := &corev1.Secret{}
+	err := s.GetObject(s.KMSSecretName(), secret)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && len(secret.Data[keyName]) > 0 {
+		s.SetRootKeyStatus()
+		return nil
+	}
+
+	canGenerate, genErr := s.CanGenerateRootKey()
+	if genErr != nil {
+		return genErr
+	}
+	if !canGenerate {
+		return s.RejectMissingRootKey(`Root master key "%s" is missing from secret "%s"`, keyName, s.KMSSecretName())
+	}
+
+	log.Infof("Generating root master key %s in secret %s", keyName, s.KMSSecretName())
+	if errors.IsNotFound(err) {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: s.Request.Namespace,
+				Name:      s.KMSSecretName(),
+				Labels:    map[string]string{"app": "noobaa"},
+			},
+			Type:       corev1.SecretTypeOpaque,
+			StringData: map[string]string{keyName: randomBase64(32)},
+		}
+		err = s.Client.Create(s.Ctx, secret)
+	} else {
+		SecretResetStringDataFromData(secret)
+		secret.StringData[keyName] = randomBase64(32)
+		err = s.Client.Update(s.Ctx, secret)
+	}
+	if err != nil {
+		return err
+	}
+	s.SetRootKeyStatus()
+	return nil
+}
+
+// ReconcileKMSVault keeps the root master key in a vault KV secrets engine.
+// The key is created with check-and-set (on KV version 2) so that a key that
+// was written concurrently is never overwritten.
+func (s *System) ReconcileKMSVault() error {
+
+	log := s.Logger.WithField("func", "ReconcileKMSVault")
+
+	v := s.NooBaa.Spec.Security.KMS.Vault
+	vault, err := s.NewVaultClient()
+	if err != nil {
+		return err
+	}
+
+	keyName := s.KMSKeyName()
+	data, version, err := vault.Read(v.Path)
+	if err != nil {
+		if s.Recorder != nil {
+			s.Recorder.Eventf(s.NooBaa, corev1.EventTypeWarning, "KMSUnavailable",
+				`Failed reading root master key from vault "%s": %s`, v.Address, err)
+		}
+		return err
+	}
+	if data[keyName] != "" {
+		s.SetRootKeyStatus()
+		return nil
+	}
+
+	canGenerate, err := s.CanGenerateRootKey()
+	if err != nil {
+		return err
+	}
+	if !canGenerate {
+		return s.RejectMissingRootKey(`Root master key "%s" is missing from vault path "%s"`, keyName, v.Path)
+	}
+
+	log.Infof("Generating root master key %s in vault path %s", keyName, v.Path)
+	if data == nil {
+		data = map[string]string{}
+	}
+	data[keyName] = randomBase64(32)
+	if err := vault.Write(v.Path, data, version); err != nil {
+		if s.Recorder != nil {
+			s.Recorder.Eventf(s.NooBaa, corev1.EventTypeWarning, "KMSUnavailable",
+				`Failed writing root master key to vault "%s": %s`, v.Address, err)
+		}
+		return err
+	}
+	if s.Recorder != nil {
+		s.Recorder.Eventf(s.NooBaa, corev1.EventTypeNormal, "KMSKeyCreated",
+			`Created root master key %s in vault path %s`, keyName, v.Path)
+	}
+	s.SetRootKeyStatus()
+	return nil
+}
+
+// CanGenerateRootKey returns true only on the first install of the system,
+// when the core statefulset was not created yet and no root master key was recorded in the status.
+// Generating a key for an existing system would replace the key that its data is encrypted with.
+func (s *System) CanGenerateRootKey() (bool, error) {
+	if s.NooBaa.Status.KMS != nil {
+		return false, nil
+	}
+	err := s.GetObject(s.CoreApp.Name, &appsv1.StatefulSet{})
+	if errors.IsNotFound(err) {
+		return true, nil
+	}
+	return false, err
+}
+
+// RejectMissingRootKey rejects a system whose root master key is missing from the kms provider,
+// which requires restoring the key (or fixing the kms spec) rather than retrying.
+func (s *System) RejectMissingRootKey(format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	s.Logger.Errorf("%s", msg)
+	if s.Recorder != nil {
+		s.Recorder.Event(s.NooBaa, corev1.EventTypeWarning, "MissingRootKey", msg)
+	}
+	s.SetPhase(nbv1.SystemPhaseRejected)
+	return NewPersistentError(fmt.Errorf("%s", msg))
+}
+
+// SetRootKeyStatus records the root master key of the system in the status
+func (s *System) SetRootKeyStatus() {
+	kms := s.NooBaa.Spec.Security.KMS
+	s.NooBaa.Status.KMS = &nbv1.KMSStatus{Provider: kms.Provider, KeyName: s.KMSKeyName()}
+}
+
+// SetDesiredKMS configures the server container with a reference to the root master key.
+// The key itself is never rendered into the pod spec - the kubernetes provider passes a secret key ref,
+// and the vault provider passes the vault address, path and a secret key ref of the token
+// for the server to read the key from vault.
+func (s *System) SetDesiredKMS() {
+	kms := s.NooBaa.Spec.Security.KMS
+	if kms == nil {
+		return
+	}
+	podSpec := &s.CoreApp.Spec.Template.Spec
+	if kms.Provider == nbv1.KMSProviderVault && kms.Vault.CASecret != nil {
+		setVolume(podSpec, corev1.Volume{
+			Name: "kms-vault-ca",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: kms.Vault.CASecret.Name,
+					Items:      []corev1.KeyToPath{{Key: "ca.crt", Path: "ca.crt"}},
+				},
+			},
+		})
+	}
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		if c.Name != "noobaa-server" {
+			continue
+		}
+		setEnv(c, corev1.EnvVar{Name: "KMS_PROVIDER", Value: string(kms.Provider)})
+		setEnv(c, corev1.EnvVar{Name: "ROOT_KEY_NAME", Value: s.KMSKeyName()})
+		switch kms.Provider {
+		case nbv1.KMSProviderKubernetes:
+			setEnv(c, corev1.EnvVar{
+				Name: "ROOT_KEY",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: s.KMSSecretName()},
+						Key:                  s.KMSKeyName(),
+					},
+				},
+			})
+		case nbv1.KMSProviderVault:
+			v := kms.Vault
+			kvVersion := v.KVVersion
+			if kvVersion == 0 {
+				kvVersion = 2
+			}
+			setEnv(c, corev1.EnvVar{Name: "VAULT_ADDR", Value: v.Address})
+			setEnv(c, corev1.EnvVar{Name: "VAULT_PATH", Value: v.Path})
+			setEnv(c, corev1.EnvVar{Name: "VAULT_KV_VERSION", Value: fmt.Sprint(kvVersion)})
+			setEnv(c, corev1.EnvVar{
+				Name: "VAULT_TOKEN",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: v.TokenSecret,
+						Key:                  "token",
+					},
+				},
+			})
+			if v.CASecret != nil {
+				setEnv(c, corev1.EnvVar{Name: "VAULT_CACERT", Value: KMSVaultCAMountPath + "/ca.crt"})
+				setVolumeMount(c, corev1.VolumeMount{
+					Name:      "kms-vault-ca",
+					MountPath: KMSVaultCAMountPath,
+					ReadOnly:  true,
+				})
+			}
+		}
+	}
+}
+
+// VaultClient is a minimal client of the vault KV secrets engine (versions 1 and 2)
+type VaultClient struct {
+	Address   string
+	Token     string
+	KVVersion int32
+	HTTP      *http.Client
+}
+
+// NewVaultClient creates a vault client from the kms spec and the token and CA secrets
+func (s *System) NewVaultClient() (*VaultClient, error) {
+	v := s.NooBaa.Spec.Security.KMS.Vault
+
+	tokenSecret := &corev1.Secret{}
+	if err := s.GetObject(v.TokenSecret.Name, tokenSecret); err != nil {
+		return nil, err
+	}
+	token := strings.TrimSpace(string(tokenSecret.Data["token"]))
+	if token == "" {
+		return nil, fmt.Errorf(`vault token secret "%s" is missing the "token" key`, tokenSecret.Name)
+	}
+
+	tlsConfig := &tls.Config{}
+	if v.CASecret != nil {
+		caSecret := &corev1.Secret{}
+		if err := s.GetObject(v.CASecret.Name, caSecret); err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caSecret.Data["ca.crt"]) {
+			return nil, fmt.Errorf(`vault CA secret "%s" has no valid "ca.crt" key`, caSecret.Name)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	kvVersion := v.KVVersion
+	if kvVersion == 0 {
+		kvVersion = 2
+	}
+	return &VaultClient{
+		Address:   strings.TrimSuffix(v.Address, "/"),
+		Token:     token,
+		KVVersion: kvVersion,
+		HTTP: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+// Read returns the data of a KV secret (nil if not found) and its version (KV version 2)
+func (c *VaultClient) Read(path string) (map[string]string, int, error) {
+	res := struct {
+		Data json.RawMessage `json:"data"`
+	}{}
+	found, err := c.do("GET", c.apiPath(path), nil, &res)
+	if err != nil || !found {
+		return nil, 0, err
+	}
+	if c.KVVersion == 1 {
+		data := map[string]string{}
+		err := json.Unmarshal(res.Data, &data)
+		return data, 0, err
+	}
+	v2 := struct {
+		Data     map[string]string `json:"data"`
+		Metadata struct {
+			Version int `json:"version"`
+		} `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(res.Data, &v2); err != nil {
+		return nil, 0, err
+	}
+	return v2.Data, v2.Metadata.Version, nil
+}
+
+// Write writes the data of a KV secret, and on KV version 2 only if the secret version
+// is still the version that was read (0 when the secret did not exist).
+func (c *VaultClient) Write(path string, data map[string]string, version int) error {
+	var body interface{} = data
+	if c.KVVersion != 1 {
+		body = map[string]interface{}{
+			"options": map[string]interface{}{"cas": version},
+			"data":    data,
+		}
+	}
+	_, err := c.do("POST", c.apiPath(path), body, nil)
+	return err
+}
+
+// apiPath returns the api path of a KV secret, where version 2 adds "data" after the mount
+func (c *VaultClient) apiPath(path string) string {
+	path = strings.Trim(path, "/")
+	if c.KVVersion == 1 {
+		return "/v1/" + path
+	}
+	parts := strings.SplitN(path, "/", 2)
+	return "/v1/" + parts[0] + "/data/" + parts[1]
+}
+
+func (c *VaultClient) do(method string, path string, body interface{}, reply interface{}) (bool, error) {
+	var reqBody []byte
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return false, err
+		}
+		reqBody = b
+	}
+	req, err := http.NewRequest(method, c.Address+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("X-Vault-Token", c.Token)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return false, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if res.StatusCode >= 300 {
+		return false, fmt.Errorf("vault %s %s: %s %s", method, path, res.Status, strings.TrimSpace(string(resBody)))
+	}
+	if reply != nil && len(resBody) > 0 {
+		if err := json.Unmarshal(resBody, reply); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,196 @@
+package system
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestVaultAPIPath(t *testing.T) {
+	tests := []struct {
+		kvVersion int32
+		path      string
+		expected  string
+	}{
+		{2, "secret/noobaa", "/v1/secret/data/noobaa"},
+		{2, "/secret/noobaa/", "/v1/secret/data/noobaa"},
+		{2, "kv/clusters/prod/noobaa", "/v1/kv/data/clusters/prod/noobaa"},
+		{1, "secret/noobaa", "/v1/secret/noobaa"},
+		{1, "/kv/clusters/noobaa/", "/v1/kv/clusters/noobaa"},
+	}
+	for _, test := range tests {
+		c := &VaultClient{KVVersion: test.kvVersion}
+		if apiPath := c.apiPath(test.path); apiPath != test.expected {
+			t.Errorf("expected KV v%d path %q to be %q, got %q", test.kvVersion, test.path, test.expected, apiPath)
+		}
+	}
+}
+
+// testVault is a fake vault server with a single KV version 2 secret that requires check-and-set
+type testVault struct {
+	*httptest.Server
+	Path    string
+	Data    map[string]string
+	Version int
+}
+
+func newTestVault(t *testing.T, path string) *testVault {
+	v := &testVault{Path: path}
+	v.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != v.Path {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			if v.Data == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+				"data":     v.Data,
+				"metadata": map[string]interface{}{"version": v.Version},
+			}})
+		case "POST":
+			body := struct {
+				Options struct {
+					CAS *int `json:"cas"`
+				} `json:"options"`
+				Data map[string]string `json:"data"`
+			}{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("fake vault failed to decode request: %s", err)
+			}
+			if body.Options.CAS == nil || *body.Options.CAS != v.Version {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			v.Data = body.Data
+			v.Version++
+		}
+	}))
+	return v
+}
+
+func TestReconcileKMSVault(t *testing.T) {
+	vault := newTestVault(t, "/v1/secret/data/noobaa")
+	defer vault.Close()
+
+	tokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "vault-token"},
+		Data:       map[string][]byte{"token": []byte("test-vault-token\n")},
+	}
+	s := newTestSystem(tokenSecret)
+	s.NooBaa.Spec.Security.KMS = &nbv1.KMSSpec{
+		Provider: nbv1.KMSProviderVault,
+		Vault: &nbv1.VaultSpec{
+			Address:     vault.URL + "/",
+			Path:        "secret/noobaa",
+			TokenSecret: corev1.LocalObjectReference{Name: "vault-token"},
+		},
+	}
+
+	if err := s.ReconcileKMS(); err != nil {
+		t.Fatal(err)
+	}
+	key := vault.Data[s.KMSKeyName()]
+	if key == "" || vault.Version != 1 {
+		t.Fatalf("expected the root master key to be created, got %v (version %d)", vault.Data, vault.Version)
+	}
+
+	// an existing key is kept
+	if err := s.ReconcileKMS(); err != nil {
+		t.Fatal(err)
+	}
+	if vault.Data[s.KMSKeyName()] != key || vault.Version != 1 {
+		t.Errorf("expected the root master key to be kept, got %v (version %d)", vault.Data, vault.Version)
+	}
+
+	if s.NooBaa.Status.KMS == nil || s.NooBaa.Status.KMS.KeyName != s.KMSKeyName() {
+		t.Errorf("expected the root master key to be recorded in the status, got %+v", s.NooBaa.Status.KMS)
+	}
+
+	// a key that is lost after it was recorded is never replaced
+	vault.Data = map[string]string{"other": "value"}
+	testRejectMissingRootKey(t, s)
+	if vault.Data[s.KMSKeyName()] != "" || vault.Version != 1 {
+		t.Errorf("expected the root master key not to be generated, got %v (version %d)", vault.Data, vault.Version)
+	}
+
+	// other keys in the secret are kept when the key is added on the first install
+	s.NooBaa.Status.KMS = nil
+	if err := s.ReconcileKMS(); err != nil {
+		t.Fatal(err)
+	}
+	if vault.Data["other"] != "value" || vault.Data[s.KMSKeyName()] == "" || vault.Version != 2 {
+		t.Errorf("expected the root master key to be added to the secret, got %v (version %d)", vault.Data, vault.Version)
+	}
+}
+
+// testRejectMissingRootKey checks that the reconcile rejects the system instead of generating a root master key
+func testRejectMissingRootKey(t *testing.T, s *System) {
+	err := s.ReconcileKMS()
+	if !IsPersistentError(err) {
+		t.Errorf("expected a persistent error for a missing root master key, got %v", err)
+	}
+	if s.NooBaa.Status.Phase != nbv1.SystemPhaseRejected {
+		t.Errorf("expected phase %s, got %s", nbv1.SystemPhaseRejected, s.NooBaa.Status.Phase)
+	}
+}
+
+func TestReconcileKMSKubernetes(t *testing.T) {
+	s := newTestSystem()
+	s.NooBaa.Spec.Security.KMS = &nbv1.KMSSpec{Provider: nbv1.KMSProviderKubernetes}
+	secret := &corev1.Secret{}
+
+	// the first install generates the key
+	if err := s.ReconcileKMS(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.GetObject(s.KMSSecretName(), secret); err != nil {
+		t.Fatal(err)
+	}
+	key := string(secret.Data[s.KMSKeyName()])
+	if key == "" || s.NooBaa.Status.KMS == nil {
+		t.Fatalf("expected the root master key to be created and recorded, got %v %+v", secret.Data, s.NooBaa.Status.KMS)
+	}
+
+	// a deleted key of a recorded system is not replaced
+	if err := s.Client.Delete(s.Ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	testRejectMissingRootKey(t, s)
+	if err := s.GetObject(s.KMSSecretName(), &corev1.Secret{}); err == nil {
+		t.Errorf("expected the root master key secret not to be created")
+	}
+}
+
+func TestReconcileKMSExistingSystem(t *testing.T) {
+	// an existing system (with a core statefulset) that enables kms without copying its key is rejected
+	s := newTestSystem()
+	if err := s.Client.Create(s.Ctx, s.CoreApp.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: s.KMSSecretName()},
+		Data:       map[string][]byte{s.KMSKeyName(): []byte("")},
+	}
+	if err := s.Client.Create(s.Ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	s.NooBaa.Spec.Security.KMS = &nbv1.KMSSpec{Provider: nbv1.KMSProviderKubernetes}
+	testRejectMissingRootKey(t, s)
+	if s.NooBaa.Status.KMS != nil {
+		t.Errorf("expected no root master key to be recorded, got %+v", s.NooBaa.Status.KMS)
+	}
+}
@@ -0,0 +1,152 @@
+package system
+
+import (
+	"sync"
+	"time"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// These are the values of the result label of ReconcileResults
+const (
+	ReconcileResultSuccess         = "success"
+	ReconcileResultTemporaryError  = "temporary_error"
+	ReconcileResultPersistentError = "persistent_error"
+)
+
+var (
+	// SystemPhases is the list of all phases used to reset the phase gauge
+	SystemPhases = []nbv1.SystemPhase{
+		nbv1.SystemPhaseRejected,
+		nbv1.SystemPhaseVerifying,
+		nbv1.SystemPhaseCreating,
+		nbv1.SystemPhaseWaitingToConnect,
+		nbv1.SystemPhaseUpgrading,
+		nbv1.SystemPhaseConfiguring,
+		nbv1.SystemPhaseReady,
+		nbv1.SystemPhaseDeleting,
+	}
+
+	// SystemPhaseGauge is 1 for the current phase of the system and 0 for the rest
+	SystemPhaseGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "noobaa_operator_system_phase",
+		Help: "Current phase of the noobaa system (1 for the current phase, 0 for the rest)",
+	}, []string{"namespace", "system", "phase"})
+
+	// SystemPhaseSince is the unix time of the last phase transition of the system.
+	// Every reconcile passes through the phases before the one it stops at (Verifying -> Creating -> ...),
+	// so this is the time of the last transition and not the time the system first reached its phase.
+	SystemPhaseSince = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "noobaa_operator_system_phase_since_timestamp_seconds",
+		Help: "Unix time when the noobaa system entered its current phase",
+	}, []string{"namespace", "system"})
+
+	// SystemPhaseDuration is a histogram of the time systems spent in each phase before moving to the next
+	SystemPhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "noobaa_operator_system_phase_duration_seconds",
+		Help:    "Time that noobaa systems spent in a phase before moving to another phase",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200},
+	}, []string{"phase"})
+
+	// ReconcileDuration is a histogram of the reconcile duration per system
+	ReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "noobaa_operator_system_reconcile_duration_seconds",
+		Help: "Duration of noobaa system reconciles",
+	}, []string{"namespace", "system"})
+
+	// ReconcileResults counts the reconcile outcomes per system
+	ReconcileResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "noobaa_operator_system_reconcile_total",
+		Help: "Total number of noobaa system reconciles per result (success, temporary_error, persistent_error)",
+	}, []string{"namespace", "system", "result"})
+
+	// phaseTrackers keep the current phase of each system and the time of the transition to it (see ObservePhase)
+	phaseTrackers     = map[types.NamespacedName]*phaseTracker{}
+	phaseTrackersLock sync.Mutex
+)
+
+type phaseTracker struct {
+	Phase nbv1.SystemPhase
+	Since time.Time
+}
+
+func init() {
+	metrics.Registry.MustRegister(
+		SystemPhaseGauge,
+		SystemPhaseSince,
+		SystemPhaseDuration,
+		ReconcileDuration,
+		ReconcileResults,
+		systemStatsCollector,
+	)
+}
+
+// ObserveReconcile updates the reconcile metrics at the end of a reconcile
+func (s *System) ObserveReconcile(start time.Time, result string) {
+	ns := s.Request.Namespace
+	name := s.Request.Name
+	ReconcileDuration.WithLabelValues(ns, name).Observe(time.Since(start).Seconds())
+	ReconcileResults.WithLabelValues(ns, name, result).Inc()
+}
+
+// ObservePhase records a phase transition of the system when it happens (see SetPhase),
+// observing the time spent in the previous phase and updating the phase gauges.
+func (s *System) ObservePhase(phase nbv1.SystemPhase, now time.Time) {
+	ns := s.Request.Namespace
+	name := s.Request.Name
+
+	phaseTrackersLock.Lock()
+	defer phaseTrackersLock.Unlock()
+
+	tracker := phaseTrackers[s.Request]
+	if tracker != nil && tracker.Phase == phase {
+		return
+	}
+	if tracker == nil {
+		tracker = &phaseTracker{}
+		phaseTrackers[s.Request] = tracker
+	} else {
+		SystemPhaseDuration.WithLabelValues(string(tracker.Phase)).Observe(now.Sub(tracker.Since).Seconds())
+		s.Logger.Infof("Phase changed from %s to %s after %s",
+			tracker.Phase, phase, now.Sub(tracker.Since).Round(time.Millisecond))
+	}
+	tracker.Phase = phase
+	tracker.Since = now
+
+	for _, p := range SystemPhases {
+		value := 0.0
+		if p == phase {
+			value = 1.0
+		}
+		SystemPhaseGauge.WithLabelValues(ns, name, string(p)).Set(value)
+	}
+	SystemPhaseSince.WithLabelValues(ns, name).Set(float64(now.Unix()))
+}
+
+// ForgetMetrics removes the metrics of a system that was deleted
+func (s *System) ForgetMetrics() {
+	ns := s.Request.Namespace
+	name := s.Request.Name
+
+	s.forgetStats()
+
+	phaseTrackersLock.Lock()
+	defer phaseTrackersLock.Unlock()
+
+	if _, exists := phaseTrackers[s.Request]; !exists {
+		return
+	}
+	delete(phaseTrackers, s.Request)
+	for _, p := range SystemPhases {
+		SystemPhaseGauge.DeleteLabelValues(ns, name, string(p))
+	}
+	SystemPhaseSince.DeleteLabelValues(ns, name)
+	ReconcileDuration.DeleteLabelValues(ns, name)
+	ReconcileResults.DeleteLabelValues(ns, name, ReconcileResultSuccess)
+	ReconcileResults.DeleteLabelValues(ns, name, ReconcileResultTemporaryError)
+	ReconcileResults.DeleteLabelValues(ns, name, ReconcileResultPersistentError)
+}
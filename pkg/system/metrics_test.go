@@ -0,0 +1,57 @@
+package system
+
+import (
+	"testing"
+	"time"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// testMetric reads the current value of a metric
+func testMetric(t *testing.T, m interface{}) *dto.Metric {
+	metric := &dto.Metric{}
+	if err := m.(prometheus.Metric).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric
+}
+
+// TestObservePhase checks that the phase transitions are recorded when the phase is set,
+// and not only at the end of the reconcile
+func TestObservePhase(t *testing.T) {
+	s := newTestSystem()
+	s.Request.Name = "test-phase"
+	defer s.ForgetMetrics()
+
+	duration := SystemPhaseDuration.WithLabelValues(string(nbv1.SystemPhaseCreating))
+	count := testMetric(t, duration).Histogram.GetSampleCount()
+	sum := testMetric(t, duration).Histogram.GetSampleSum()
+
+	start := time.Unix(1000, 0)
+	s.ObservePhase(nbv1.SystemPhaseVerifying, start)
+	s.ObservePhase(nbv1.SystemPhaseCreating, start.Add(time.Second))
+	s.ObservePhase(nbv1.SystemPhaseCreating, start.Add(5*time.Second))
+	s.ObservePhase(nbv1.SystemPhaseWaitingToConnect, start.Add(11*time.Second))
+
+	h := testMetric(t, duration).Histogram
+	if h.GetSampleCount() != count+1 || h.GetSampleSum() != sum+10 {
+		t.Errorf("expected one transition from Creating after 10s, got count=%d sum=%f",
+			h.GetSampleCount()-count, h.GetSampleSum()-sum)
+	}
+	since := testMetric(t, SystemPhaseSince.WithLabelValues(testNamespace, s.Request.Name)).Gauge.GetValue()
+	if since != float64(start.Add(11*time.Second).Unix()) {
+		t.Errorf("expected the time of the transition to WaitingToConnect, got %f", since)
+	}
+
+	// the phase gauges are updated by SetPhase during the reconcile
+	s.SetPhase(nbv1.SystemPhaseConfiguring)
+	for _, p := range SystemPhases {
+		value := testMetric(t, SystemPhaseGauge.WithLabelValues(testNamespace, s.Request.Name, string(p))).Gauge.GetValue()
+		if (p == nbv1.SystemPhaseConfiguring) != (value == 1) {
+			t.Errorf("expected phase %s gauge to be set only for the current phase, got %f", p, value)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package system
+
+import (
+	"strings"
+
+	"github.com/noobaa/noobaa-operator/build/_output/bundle"
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+	"github.com/noobaa/noobaa-operator/pkg/util"
+
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ReconcileMonitoring reconciles the ServiceMonitors and PrometheusRule of the system.
+// These are only reconciled when the monitoring.coreos.com API is installed (prometheus-operator),
+// otherwise we just skip and let the system work without them.
+// The core metrics are scraped from the mgmt service, and the operator metrics (which the system alerts are based on)
+// are scraped from a service of the operator pod.
+func (s *System) ReconcileMonitoring() error {
+
+	log := s.Logger.WithField("func", "ReconcileMonitoring")
+
+	err := s.ReconcileObject(s.ServiceMonitor, s.SetDesiredServiceMonitor)
+	if meta.IsNoMatchError(err) {
+		log.Infof("Monitoring API (monitoring.coreos.com/v1) not found. Skipping ServiceMonitor and PrometheusRule.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.ReconcileObject(s.ServiceOperatorMetrics, s.SetDesiredServiceOperatorMetrics); err != nil {
+		return err
+	}
+	if err := s.ReconcileObject(s.ServiceMonitorOperator, s.SetDesiredServiceMonitorOperator); err != nil {
+		return err
+	}
+	return s.ReconcileObject(s.PrometheusRule, s.SetDesiredPrometheusRule)
+}
+
+// SetDesiredServiceMonitor updates the ServiceMonitor as desired for reconciling
+func (s *System) SetDesiredServiceMonitor() {
+	s.ServiceMonitor.Spec.Selector.MatchLabels = map[string]string{"noobaa-mgmt-svc": s.Request.Name}
+	desired := util.KubeObject(bundle.File_deploy_internal_servicemonitor_mgmt_yaml).(*monitoringv1.ServiceMonitor)
+	s.ServiceMonitor.Spec.Endpoints = desired.Spec.Endpoints
+}
+
+// SetDesiredServiceOperatorMetrics updates the service of the operator metrics as desired for reconciling
+func (s *System) SetDesiredServiceOperatorMetrics() {
+	desired := util.KubeObject(bundle.File_deploy_internal_service_operator_metrics_yaml).(*corev1.Service)
+	if s.ServiceOperatorMetrics.Labels == nil {
+		s.ServiceOperatorMetrics.Labels = map[string]string{}
+	}
+	s.ServiceOperatorMetrics.Labels["noobaa-operator-metrics-svc"] = s.Request.Name
+	s.ServiceOperatorMetrics.Spec.Selector = desired.Spec.Selector
+	s.ServiceOperatorMetrics.Spec.Ports = desired.Spec.Ports
+}
+
+// SetDesiredServiceMonitorOperator updates the ServiceMonitor of the operator metrics as desired for reconciling.
+// Only the metrics of the system are kept, since every system in the namespace scrapes the same operator.
+func (s *System) SetDesiredServiceMonitorOperator() {
+	s.ServiceMonitorOperator.Spec.Selector.MatchLabels = map[string]string{"noobaa-operator-metrics-svc": s.Request.Name}
+	desired := util.KubeObject(bundle.File_deploy_internal_servicemonitor_operator_yaml).(*monitoringv1.ServiceMonitor)
+	for _, endpoint := range desired.Spec.Endpoints {
+		for _, relabel := range endpoint.MetricRelabelConfigs {
+			relabel.Regex = strings.Replace(relabel.Regex, "SYSNAME", s.Request.Name, -1)
+		}
+	}
+	s.ServiceMonitorOperator.Spec.Endpoints = desired.Spec.Endpoints
+}
+
+// DBVolumeAlert is the alert on the free space of the db volume,
+// which is not rendered when using an external db since there is no db volume.
+const DBVolumeAlert = "NooBaaDBVolumeNearlyFull"
+
+// SetDesiredPrometheusRule updates the PrometheusRule as desired for reconciling.
+// The rules are always reset from the bundled template so that operator upgrades update the alerts.
+// The template placeholders are replaced with the system names to scope the rule expressions.
+func (s *System) SetDesiredPrometheusRule() {
+	desired := util.KubeObject(bundle.File_deploy_internal_prometheus_rules_yaml).(*monitoringv1.PrometheusRule)
+	// the statefulset pvc name is <template>-<statefulset>-<ordinal>
+	dbPVCName := s.DBVolumeClaimName()
+	replacer := strings.NewReplacer(
+		"NAMESPACE", s.Request.Namespace,
+		"SYSNAME", s.Request.Name,
+		"DB_PVC_NAME", dbPVCName,
+	)
+	for i := range desired.Spec.Groups {
+		group := &desired.Spec.Groups[i]
+		group.Name = replacer.Replace(group.Name)
+		rules := group.Rules[:0]
+		for j := range group.Rules {
+			rule := group.Rules[j]
+			if rule.Alert == DBVolumeAlert && s.NooBaa.Spec.DBType == nbv1.DBTypeExternal {
+				continue
+			}
+			rule.Expr = intstr.FromString(replacer.Replace(rule.Expr.String()))
+			for k, v := range rule.Annotations {
+				rule.Annotations[k] = replacer.Replace(v)
+			}
+			rules = append(rules, rule)
+		}
+		group.Rules = rules
+	}
+	s.PrometheusRule.Spec = desired.Spec
+}
@@ -0,0 +1,101 @@
+package system
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/noobaa/noobaa-operator/build/_output/bundle"
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+	"github.com/noobaa/noobaa-operator/pkg/util"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// testAlerts returns the count of each rendered alert of the system
+func testAlerts(t *testing.T, s *System) map[string]int {
+	alerts := map[string]int{}
+	for _, group := range s.PrometheusRule.Spec.Groups {
+		for _, rule := range group.Rules {
+			alerts[rule.Alert]++
+			if strings.Contains(rule.Expr.String(), "DB_PVC_NAME") || strings.Contains(rule.Expr.String(), "SYSNAME") {
+				t.Errorf("expected the placeholders to be replaced, got %s", rule.Expr.String())
+			}
+		}
+	}
+	return alerts
+}
+
+func TestSetDesiredPrometheusRule(t *testing.T) {
+	s := newTestSystem()
+	s.SetDesiredPrometheusRule()
+	alerts := testAlerts(t, s)
+	if alerts[DBVolumeAlert] != 2 || alerts["NooBaaSystemNotReady"] != 1 {
+		t.Errorf("expected the db volume alerts to be rendered, got %v", alerts)
+	}
+
+	s = newTestSystem()
+	s.NooBaa.Spec.DBType = nbv1.DBTypeExternal
+	s.SetDesiredPrometheusRule()
+	alerts = testAlerts(t, s)
+	if alerts[DBVolumeAlert] != 0 || alerts["NooBaaSystemNotReady"] != 1 {
+		t.Errorf("expected no db volume alerts with an external db, got %v", alerts)
+	}
+}
+
+// TestOperatorMetricsService checks that the operator metrics service of the system selects the pod
+// and the metrics port of the bundled operator deployment, and that only the metrics of the system are kept
+func TestOperatorMetricsService(t *testing.T) {
+	s := newTestSystem()
+	s.SetDesiredServiceOperatorMetrics()
+	s.SetDesiredServiceMonitorOperator()
+
+	deployment := util.KubeObject(bundle.File_deploy_operator_yaml).(*appsv1.Deployment)
+	for key, value := range s.ServiceOperatorMetrics.Spec.Selector {
+		if deployment.Spec.Template.Labels[key] != value {
+			t.Errorf("expected the service selector %s=%s to match the operator pod labels %v", key, value, deployment.Spec.Template.Labels)
+		}
+	}
+	port := s.ServiceOperatorMetrics.Spec.Ports[0]
+	declared := false
+	for _, p := range deployment.Spec.Template.Spec.Containers[0].Ports {
+		if p.Name == port.Name && p.ContainerPort == port.Port {
+			declared = true
+		}
+	}
+	if !declared {
+		t.Errorf("expected the operator container to declare the metrics port %+v", port)
+	}
+
+	selector := s.ServiceMonitorOperator.Spec.Selector.MatchLabels
+	for key, value := range selector {
+		if s.ServiceOperatorMetrics.Labels[key] != value {
+			t.Errorf("expected the service monitor selector %v to match the service labels %v", selector, s.ServiceOperatorMetrics.Labels)
+		}
+	}
+	endpoint := s.ServiceMonitorOperator.Spec.Endpoints[0]
+	if endpoint.Port != port.Name || !endpoint.HonorLabels {
+		t.Errorf("expected the service monitor to scrape the metrics port with the metrics labels, got %+v", endpoint)
+	}
+	relabel := endpoint.MetricRelabelConfigs[0]
+	if relabel.Action != "keep" || relabel.Regex != testName || relabel.SourceLabels[0] != "system" {
+		t.Errorf("expected to keep only the metrics of the system, got %+v", relabel)
+	}
+}
+
+// TestAlertMetrics checks that the alerts only use metrics that are scraped,
+// either from the operator (noobaa_operator_*) or from the kubelet
+func TestAlertMetrics(t *testing.T) {
+	s := newTestSystem()
+	s.SetDesiredPrometheusRule()
+	for _, group := range s.PrometheusRule.Spec.Groups {
+		for _, rule := range group.Rules {
+			expr := rule.Expr.String()
+			if !strings.Contains(expr, "noobaa_operator_") && !strings.Contains(expr, "kubelet_volume_stats_") {
+				t.Errorf("expected alert %s to use the operator or kubelet metrics, got %s", rule.Alert, expr)
+			}
+			if strings.Contains(expr, "noobaa_operator_") && !strings.Contains(expr, `system="`+testName+`"`) {
+				t.Errorf("expected alert %s to select the metrics of the system, got %s", rule.Alert, expr)
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package system
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ClusterProxyName is the name of the OpenShift cluster-wide proxy object
+	ClusterProxyName = "cluster"
+
+	// ProxyNoProxyDefaults are the cluster internal addresses that never use the proxy
+	ProxyNoProxyDefaults = "localhost,127.0.0.1,.svc,.cluster.local"
+)
+
+// ClusterProxyGVK is the kind of the OpenShift cluster-wide proxy object
+var ClusterProxyGVK = schema.GroupVersionKind{Group: "config.openshift.io", Version: "v1", Kind: "Proxy"}
+
+// ReconcileProxy sets the effective proxy configuration of the system from the spec,
+// and populates the fields that are not set in the spec from the OpenShift cluster-wide proxy.
+// The proxy is applied to the pods by SetDesiredProxy.
+func (s *System) ReconcileProxy() error {
+
+	log := s.Logger.WithField("func", "ReconcileProxy")
+
+	s.Proxy = s.NooBaa.Spec.Proxy
+
+	if s.ClusterReader != nil {
+		clusterProxy := &unstructured.Unstructured{}
+		clusterProxy.SetGroupVersionKind(ClusterProxyGVK)
+		err := s.ClusterReader.Get(s.Ctx, client.ObjectKey{Name: ClusterProxyName}, clusterProxy)
+		if err == nil {
+			// the status has the effective values (the noProxy of the status also includes the cluster networks)
+			status, _, _ := unstructured.NestedStringMap(clusterProxy.Object, "status")
+			if s.Proxy.HTTPProxy == "" {
+				s.Proxy.HTTPProxy = status["httpProxy"]
+			}
+			if s.Proxy.HTTPSProxy == "" {
+				s.Proxy.HTTPSProxy = status["httpsProxy"]
+			}
+			if s.Proxy.NoProxy == "" {
+				s.Proxy.NoProxy = status["noProxy"]
+			}
+		} else if !meta.IsNoMatchError(err) && !errors.IsNotFound(err) {
+			// not fatal - the spec proxy is still applied
+			log.Warnf("Failed reading cluster proxy: %s", err)
+		}
+	}
+
+	if s.Proxy.HTTPProxy == "" && s.Proxy.HTTPSProxy == "" {
+		s.Proxy.NoProxy = ""
+		s.NooBaa.Status.Proxy = nil
+		return nil
+	}
+
+	noProxy := []string{}
+	for _, host := range strings.Split(s.Proxy.NoProxy+","+ProxyNoProxyDefaults, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" || contains(noProxy, host) {
+			continue
+		}
+		noProxy = append(noProxy, host)
+	}
+	s.Proxy.NoProxy = strings.Join(noProxy, ",")
+
+	proxy := s.Proxy
+	s.NooBaa.Status.Proxy = &proxy
+	return nil
+}
+
+// SetDesiredProxy sets the proxy env variables on the server container.
+// Both upper and lower case variables are set since different http clients read different ones.
+func (s *System) SetDesiredProxy() {
+	podSpec := &s.CoreApp.Spec.Template.Spec
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		if c.Name != "noobaa-server" {
+			continue
+		}
+		setProxyEnv(c, "HTTP_PROXY", s.Proxy.HTTPProxy)
+		setProxyEnv(c, "HTTPS_PROXY", s.Proxy.HTTPSProxy)
+		setProxyEnv(c, "NO_PROXY", s.Proxy.NoProxy)
+	}
+}
+
+func setProxyEnv(c *corev1.Container, name string, value string) {
+	if value == "" {
+		return
+	}
+	setEnv(c, corev1.EnvVar{Name: name, Value: value})
+	setEnv(c, corev1.EnvVar{Name: strings.ToLower(name), Value: value})
+}
+
+func contains(list []string, item string) bool {
+	for _, s := range list {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package system
+
+import (
+	"testing"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// testClusterProxy returns the OpenShift cluster-wide proxy with the given status
+func testClusterProxy(status map[string]interface{}) *unstructured.Unstructured {
+	clusterProxy := &unstructured.Unstructured{Object: map[string]interface{}{"status": status}}
+	clusterProxy.SetGroupVersionKind(ClusterProxyGVK)
+	clusterProxy.SetName(ClusterProxyName)
+	return clusterProxy
+}
+
+func TestReconcileProxy(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     nbv1.ProxySpec
+		cluster  map[string]interface{}
+		expected *nbv1.ProxySpec
+	}{{
+		name:     "no proxy",
+		spec:     nbv1.ProxySpec{NoProxy: "example.com"},
+		expected: nil,
+	}, {
+		name: "spec proxy",
+		spec: nbv1.ProxySpec{HTTPSProxy: "http://proxy:3128", NoProxy: " example.com,,localhost "},
+		expected: &nbv1.ProxySpec{
+			HTTPSProxy: "http://proxy:3128",
+			NoProxy:    "example.com,localhost,127.0.0.1,.svc,.cluster.local",
+		},
+	}, {
+		name: "cluster proxy",
+		cluster: map[string]interface{}{
+			"httpProxy":  "http://cluster-proxy:3128",
+			"httpsProxy": "http://cluster-proxy:3129",
+			"noProxy":    ".cluster.local,10.0.0.0/16",
+		},
+		expected: &nbv1.ProxySpec{
+			HTTPProxy:  "http://cluster-proxy:3128",
+			HTTPSProxy: "http://cluster-proxy:3129",
+			NoProxy:    ".cluster.local,10.0.0.0/16,localhost,127.0.0.1,.svc",
+		},
+	}, {
+		name: "spec overrides cluster proxy",
+		spec: nbv1.ProxySpec{HTTPSProxy: "http://proxy:3128", NoProxy: "example.com"},
+		cluster: map[string]interface{}{
+			"httpProxy":  "http://cluster-proxy:3128",
+			"httpsProxy": "http://cluster-proxy:3129",
+			"noProxy":    "10.0.0.0/16",
+		},
+		expected: &nbv1.ProxySpec{
+			HTTPProxy:  "http://cluster-proxy:3128",
+			HTTPSProxy: "http://proxy:3128",
+			NoProxy:    "example.com,localhost,127.0.0.1,.svc,.cluster.local",
+		},
+	}}
+
+	for _, test := range tests {
+		s := newTestSystem()
+		s.NooBaa.Spec.Proxy = test.spec
+		if test.cluster != nil {
+			s.ClusterReader = newTestClient(testClusterProxy(test.cluster))
+		} else {
+			s.ClusterReader = newTestClient()
+		}
+		if err := s.ReconcileProxy(); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		status := s.NooBaa.Status.Proxy
+		if test.expected == nil {
+			if status != nil || s.Proxy.NoProxy != "" {
+				t.Errorf("%s: expected no proxy, got status %+v and noProxy %q", test.name, status, s.Proxy.NoProxy)
+			}
+			continue
+		}
+		if status == nil || *status != *test.expected {
+			t.Errorf("%s: expected proxy %+v, got %+v", test.name, *test.expected, status)
+		}
+	}
+}
+
+func TestSetDesiredProxy(t *testing.T) {
+	s := newTestSystem()
+	s.Proxy = nbv1.ProxySpec{HTTPSProxy: "http://proxy:3128", NoProxy: "localhost"}
+	s.SetDesiredProxy()
+	env := map[string]string{}
+	for _, c := range s.CoreApp.Spec.Template.Spec.Containers {
+		if c.Name != "noobaa-server" {
+			continue
+		}
+		for _, e := range c.Env {
+			env[e.Name] = e.Value
+		}
+	}
+	expected := map[string]string{
+		"HTTPS_PROXY": "http://proxy:3128",
+		"https_proxy": "http://proxy:3128",
+		"NO_PROXY":    "localhost",
+		"no_proxy":    "localhost",
+	}
+	for name, value := range expected {
+		if env[name] != value {
+			t.Errorf("expected env %s=%q, got %q", name, value, env[name])
+		}
+	}
+	if _, ok := env["HTTP_PROXY"]; ok {
+		t.Errorf("expected no HTTP_PROXY env when it is not set")
+	}
+}
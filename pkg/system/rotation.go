@@ -0,0 +1,176 @@
+package system
+
+import (
+	"fmt"
+
+	"github.com/noobaa/noobaa-operator/pkg/nb"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// AnnotationRotateAdminPassword on the noobaa system requests to rotate the admin password.
+	// The value is not used (the cli sets the request time), and the operator removes the annotation when done.
+	AnnotationRotateAdminPassword = "noobaa.io/rotate-admin-password"
+
+	// AnnotationRotateAdminKeys on the noobaa system requests to rotate the admin S3 access keys.
+	// The value is not used (the cli sets the request time), and the operator removes the annotation when done.
+	AnnotationRotateAdminKeys = "noobaa.io/rotate-admin-keys"
+)
+
+// ReconcileRotation rotates the admin credentials when requested by the rotation annotations.
+// Each annotation is removed as soon as its rotation is done, so that a failure in a later step
+// does not repeat a completed rotation on the next reconcile.
+// Requires an initialized NBClient and the operator secret (see ReconcileSecretOp).
+func (s *System) ReconcileRotation() error {
+
+	log := s.Logger.WithField("func", "ReconcileRotation")
+
+	_, rotatePassword := s.NooBaa.Annotations[AnnotationRotateAdminPassword]
+	_, rotateKeys := s.NooBaa.Annotations[AnnotationRotateAdminKeys]
+	if !rotatePassword && !rotateKeys {
+		return nil
+	}
+
+	if rotatePassword {
+		if err := s.RotateAdminPassword(); err != nil {
+			log.Errorf("Failed rotating admin password: %s", err)
+			return err
+		}
+		if err := s.removeAnnotation(AnnotationRotateAdminPassword); err != nil {
+			return err
+		}
+	}
+	if rotateKeys {
+		if err := s.RotateAdminKeys(); err != nil {
+			log.Errorf("Failed rotating admin keys: %s", err)
+			return err
+		}
+		if err := s.removeAnnotation(AnnotationRotateAdminKeys); err != nil {
+			return err
+		}
+	}
+
+	// the admin secret is also reconciled on every reconcile, which repairs a failure here
+	return s.ReconcileSecretAdmin()
+}
+
+// removeAnnotation removes an annotation of a request that is done from the noobaa system.
+// Updating the metadata returns the stored status so we keep the status of this reconcile.
+func (s *System) removeAnnotation(key string) error {
+	status := s.NooBaa.Status.DeepCopy()
+	delete(s.NooBaa.Annotations, key)
+	err := s.Client.Update(s.Ctx, s.NooBaa)
+	s.NooBaa.Status = *status
+	return err
+}
+
+// RotateAdminPassword sets a new random password to the admin account (AdminAccountEmail),
+// authenticating with the current password from the admin secret.
+// The new password is first saved in the operator secret as pending_password so that
+// if the operator fails after the password was changed in the server, the next attempt
+// (or RecoverOperatorToken) will find it, and only then the password is replaced in the admin secret,
+// and in the operator secret only if the operator secret keeps the credentials of the admin account.
+func (s *System) RotateAdminPassword() error {
+
+	log := s.Logger.WithField("func", "RotateAdminPassword")
+
+	secretAdmin := &corev1.Secret{}
+	if err := s.GetObject(s.SecretAdmin.Name, secretAdmin); err != nil {
+		return err
+	}
+	email := AdminAccountEmail
+	password := string(secretAdmin.Data["password"])
+	newPassword := s.SecretOp.StringData["pending_password"]
+
+	if newPassword == "" {
+		newPassword = randomBase64(16)
+		s.SecretOp.StringData["pending_password"] = newPassword
+		if err := s.Client.Update(s.Ctx, s.SecretOp); err != nil {
+			return err
+		}
+		SecretResetStringDataFromData(s.SecretOp)
+	}
+
+	// the operator token belongs to the operator account, but changing the password
+	// requires to verify the password of the requesting account, so we call as the admin.
+	authRes, err := s.NBClient.CreateAuthAPI(nb.CreateAuthParams{
+		System:   s.Request.Name,
+		Role:     "admin",
+		Email:    email,
+		Password: password,
+	})
+	if err == nil {
+		s.NBClient.SetAuthToken(authRes.Token)
+		_, err = s.NBClient.ResetPasswordAPI(nb.ResetPasswordParams{
+			Email:                email,
+			VerificationPassword: password,
+			Password:             newPassword,
+		})
+		s.NBClient.SetAuthToken(s.SecretOp.StringData["auth_token"])
+		if err != nil {
+			return err
+		}
+	} else {
+		// a previous attempt might have changed the password without updating the secrets
+		_, pendingErr := s.NBClient.CreateAuthAPI(nb.CreateAuthParams{
+			System:   s.Request.Name,
+			Role:     "admin",
+			Email:    email,
+			Password: newPassword,
+		})
+		if pendingErr != nil {
+			return fmt.Errorf("cannot authenticate with the current or pending password: %s", err)
+		}
+		log.Infof("Password was already changed to the pending password")
+	}
+
+	SecretResetStringDataFromData(secretAdmin)
+	secretAdmin.StringData["password"] = newPassword
+	if err := s.Client.Update(s.Ctx, secretAdmin); err != nil {
+		return err
+	}
+	SecretResetStringDataFromData(secretAdmin)
+	s.SecretAdmin = secretAdmin
+
+	if s.SecretOp.StringData["email"] == email {
+		s.SecretOp.StringData["password"] = newPassword
+	}
+	delete(s.SecretOp.StringData, "pending_password")
+	if err := s.Client.Update(s.Ctx, s.SecretOp); err != nil {
+		return err
+	}
+	SecretResetStringDataFromData(s.SecretOp)
+
+	now := metav1.Now()
+	s.NooBaa.Status.Accounts.Admin.PasswordRotationTime = &now
+	log.Infof("✅ Rotated admin password")
+	if s.Recorder != nil {
+		s.Recorder.Eventf(s.NooBaa, corev1.EventTypeNormal, "AdminPasswordRotated",
+			`Rotated the password of %s in secret %s`, email, s.SecretAdmin.Name)
+	}
+	return nil
+}
+
+// RotateAdminKeys generates new S3 access keys for the admin account.
+// The new keys are written to the admin secret by ReconcileSecretAdmin,
+// which reads the keys from the account and therefore also repairs a failed attempt.
+func (s *System) RotateAdminKeys() error {
+
+	log := s.Logger.WithField("func", "RotateAdminKeys")
+
+	_, err := s.NBClient.GenerateAccountKeysAPI(nb.GenerateAccountKeysParams{Email: AdminAccountEmail})
+	if err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	s.NooBaa.Status.Accounts.Admin.KeysRotationTime = &now
+	log.Infof("✅ Rotated admin access keys")
+	if s.Recorder != nil {
+		s.Recorder.Eventf(s.NooBaa, corev1.EventTypeNormal, "AdminKeysRotated",
+			`Rotated the access keys of %s in secret %s`, AdminAccountEmail, s.SecretAdmin.Name)
+	}
+	return nil
+}
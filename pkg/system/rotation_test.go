@@ -0,0 +1,153 @@
+package system
+
+import (
+	"context"
+	"testing"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// testCallCount returns the number of calls of an api method to the fake server
+func testCallCount(srv *testServer, call string) int {
+	count := 0
+	for _, c := range srv.Calls {
+		if c == call {
+			count++
+		}
+	}
+	return count
+}
+
+// TestRotationIdempotent fails the keys rotation after the password was rotated,
+// and checks that the next reconcile only retries the keys rotation.
+func TestRotationIdempotent(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+	srv.Errors = map[string]string{"generate_account_keys": "INTERNAL"}
+
+	image := ContainerImageName + ":5.2.0"
+	nooBaa := &nbv1.NooBaa{
+		Spec:   nbv1.NooBaaSpec{Image: &image},
+		Status: nbv1.NooBaaStatus{Phase: nbv1.SystemPhaseReady, ActualImage: image},
+	}
+	nooBaa.Annotations = map[string]string{
+		AnnotationRotateAdminPassword: "now",
+		AnnotationRotateAdminKeys:     "now",
+	}
+	dbSecret := testExternalDB(nooBaa)
+	objs := append(testSystemObjects(t, srv, nooBaa), dbSecret)
+	c := newTestClient(objs...)
+	req := types.NamespacedName{Namespace: testNamespace, Name: testName}
+
+	if _, err := New(req, c, scheme.Scheme, nil).Reconcile(); err != nil {
+		t.Fatalf("reconcile failed: %s", err)
+	}
+	result := &nbv1.NooBaa{}
+	if err := c.Get(context.TODO(), req, result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Status.Phase == nbv1.SystemPhaseReady {
+		t.Errorf("expected the reconcile to fail on the keys rotation")
+	}
+	if _, ok := result.Annotations[AnnotationRotateAdminPassword]; ok {
+		t.Errorf("expected the password rotation annotation to be removed once the password was rotated")
+	}
+	if _, ok := result.Annotations[AnnotationRotateAdminKeys]; !ok {
+		t.Errorf("expected the keys rotation annotation to be kept until the keys are rotated")
+	}
+	secretOp := &corev1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: "noobaa-operator"}, secretOp); err != nil {
+		t.Fatal(err)
+	}
+	password := string(secretOp.Data["password"])
+	if password == "test-password" || password == "" {
+		t.Errorf("expected the password to be rotated, got %q", password)
+	}
+
+	srv.Errors = nil
+	srv.Calls = nil
+	if _, err := New(req, c, scheme.Scheme, nil).Reconcile(); err != nil {
+		t.Fatalf("reconcile failed: %s", err)
+	}
+	if n := testCallCount(srv, "account_api.reset_password"); n != 0 {
+		t.Errorf("expected the password not to be rotated again, got %d calls", n)
+	}
+	if n := testCallCount(srv, "account_api.generate_account_keys"); n != 1 {
+		t.Errorf("expected the keys to be rotated once, got %d calls", n)
+	}
+	result = &nbv1.NooBaa{}
+	if err := c.Get(context.TODO(), req, result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Status.Phase != nbv1.SystemPhaseReady {
+		t.Errorf("expected phase %s, got %s", nbv1.SystemPhaseReady, result.Status.Phase)
+	}
+	if len(result.Annotations) != 0 {
+		t.Errorf("expected the rotation annotations to be removed, got %v", result.Annotations)
+	}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: "noobaa-operator"}, secretOp); err != nil {
+		t.Fatal(err)
+	}
+	if string(secretOp.Data["password"]) != password {
+		t.Errorf("expected the rotated password to be kept")
+	}
+}
+
+// TestRotateAdminPasswordOfAdminAccount rotates the password when the operator secret keeps the credentials
+// of another account (recovered from the recovery secret), and checks that the admin account is rotated
+func TestRotateAdminPasswordOfAdminAccount(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+	srv.Password = "admin-password"
+
+	image := ContainerImageName + ":5.2.0"
+	nooBaa := &nbv1.NooBaa{
+		Spec:   nbv1.NooBaaSpec{Image: &image},
+		Status: nbv1.NooBaaStatus{Phase: nbv1.SystemPhaseReady, ActualImage: image},
+	}
+	nooBaa.Annotations = map[string]string{AnnotationRotateAdminPassword: "now"}
+	dbSecret := testExternalDB(nooBaa)
+	objs := append(testSystemObjects(t, srv, nooBaa), dbSecret)
+	for _, obj := range objs {
+		if secret, ok := obj.(*corev1.Secret); ok && secret.Name == testName+"-operator" {
+			secret.Data["email"] = []byte("recovery@noobaa.io")
+			secret.Data["password"] = []byte("recovery-password")
+		}
+	}
+	objs = append(objs, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName + "-admin"},
+		Data: map[string][]byte{
+			"email":    []byte(AdminAccountEmail),
+			"password": []byte("admin-password"),
+		},
+	})
+	c := newTestClient(objs...)
+	req := types.NamespacedName{Namespace: testNamespace, Name: testName}
+
+	if _, err := New(req, c, scheme.Scheme, nil).Reconcile(); err != nil {
+		t.Fatalf("reconcile failed: %s", err)
+	}
+	params := srv.Params["reset_password"]
+	if params["email"] != AdminAccountEmail || params["verification_password"] != "admin-password" {
+		t.Errorf("expected the password of the admin account to be reset with its current password, got %v", params)
+	}
+	secretAdmin := &corev1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: testName + "-admin"}, secretAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if password := string(secretAdmin.Data["password"]); password != params["password"] {
+		t.Errorf("expected the admin secret to have the new password %q, got %q", params["password"], password)
+	}
+	secretOp := &corev1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: testName + "-operator"}, secretOp); err != nil {
+		t.Fatal(err)
+	}
+	if string(secretOp.Data["password"]) != "recovery-password" || len(secretOp.Data["pending_password"]) != 0 {
+		t.Errorf("expected the operator secret to keep the credentials of the other account, got %v", secretOp.Data)
+	}
+}
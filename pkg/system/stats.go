@@ -0,0 +1,245 @@
+package system
+
+import (
+	"sync"
+	"time"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+	"github.com/noobaa/noobaa-operator/pkg/nb"
+
+	obAPI "github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// StatsInterval is the interval for reading the stats of ready systems
+	StatsInterval = 60 * time.Second
+
+	// PoolModeOptimal is the pool mode that core reports for healthy pools
+	PoolModeOptimal = "OPTIMAL"
+)
+
+// SystemStats is the last stats read from a system with the kubernetes objects that map to it
+type SystemStats struct {
+	Time          time.Time
+	System        nb.ReadSystemReply
+	BucketClaims  map[string]corev1.ObjectReference
+	BackingStores map[string]bool
+}
+
+// statsCollector is a prometheus collector that exports the last stats read from each system.
+// The stats are read periodically by CollectStats and kept in memory,
+// so that scraping the operator metrics does not call the noobaa servers.
+type statsCollector struct {
+	lock  sync.Mutex
+	stats map[types.NamespacedName]*SystemStats
+
+	systemCapacity *prometheus.Desc
+	systemObjects  *prometheus.Desc
+	systemAccounts *prometheus.Desc
+	systemStatsAge *prometheus.Desc
+	bucketObjects  *prometheus.Desc
+	bucketCapacity *prometheus.Desc
+	poolHealthy    *prometheus.Desc
+	poolCapacity   *prometheus.Desc
+}
+
+var systemStatsCollector = &statsCollector{
+	stats: map[types.NamespacedName]*SystemStats{},
+
+	systemCapacity: prometheus.NewDesc(
+		"noobaa_operator_system_capacity_bytes",
+		"Capacity of the noobaa system per type (total, free, used, used_other, unavailable_free, reserved)",
+		[]string{"namespace", "system", "type"}, nil),
+	systemObjects: prometheus.NewDesc(
+		"noobaa_operator_system_objects",
+		"Number of objects in the noobaa system",
+		[]string{"namespace", "system"}, nil),
+	systemAccounts: prometheus.NewDesc(
+		"noobaa_operator_system_accounts",
+		"Number of accounts in the noobaa system",
+		[]string{"namespace", "system"}, nil),
+	systemStatsAge: prometheus.NewDesc(
+		"noobaa_operator_system_stats_timestamp_seconds",
+		"Unix time of the last successful read of the noobaa system stats",
+		[]string{"namespace", "system"}, nil),
+	bucketObjects: prometheus.NewDesc(
+		"noobaa_operator_bucket_objects",
+		"Number of objects in the bucket (claim labels are set for buckets provisioned by an ObjectBucketClaim)",
+		[]string{"namespace", "system", "bucket", "claim_namespace", "claim_name"}, nil),
+	bucketCapacity: prometheus.NewDesc(
+		"noobaa_operator_bucket_capacity_bytes",
+		"Capacity of the bucket per type (size, size_reduced, free, available_for_upload)",
+		[]string{"namespace", "system", "bucket", "claim_namespace", "claim_name", "type"}, nil),
+	poolHealthy: prometheus.NewDesc(
+		"noobaa_operator_pool_healthy",
+		"Pool health (1 when the pool mode is OPTIMAL, 0 otherwise), backing_store is set for pools of a BackingStore",
+		[]string{"namespace", "system", "pool", "backing_store", "resource_type", "mode"}, nil),
+	poolCapacity: prometheus.NewDesc(
+		"noobaa_operator_pool_capacity_bytes",
+		"Capacity of the pool per type (total, free, used, used_other, unavailable_free, reserved)",
+		[]string{"namespace", "system", "pool", "backing_store", "type"}, nil),
+}
+
+// Describe implements prometheus.Collector
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.systemCapacity
+	ch <- c.systemObjects
+	ch <- c.systemAccounts
+	ch <- c.systemStatsAge
+	ch <- c.bucketObjects
+	ch <- c.bucketCapacity
+	ch <- c.poolHealthy
+	ch <- c.poolCapacity
+}
+
+// Collect implements prometheus.Collector
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	gauge := func(desc *prometheus.Desc, value float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+	}
+
+	for req, stats := range c.stats {
+		ns := req.Namespace
+		name := req.Name
+		sys := &stats.System
+
+		gauge(c.systemStatsAge, float64(stats.Time.Unix()), ns, name)
+		gauge(c.systemObjects, float64(sys.Objects), ns, name)
+		gauge(c.systemAccounts, float64(len(sys.Accounts)), ns, name)
+		for t, v := range storageValues(&sys.Storage) {
+			gauge(c.systemCapacity, v, ns, name, t)
+		}
+
+		for i := range sys.Buckets {
+			bucket := &sys.Buckets[i]
+			claim := stats.BucketClaims[bucket.Name]
+			gauge(c.bucketObjects, float64(bucket.NumObjects.Value),
+				ns, name, bucket.Name, claim.Namespace, claim.Name)
+			gauge(c.bucketCapacity, bucket.Data.Size.Float64(),
+				ns, name, bucket.Name, claim.Namespace, claim.Name, "size")
+			gauge(c.bucketCapacity, bucket.Data.SizeReduced.Float64(),
+				ns, name, bucket.Name, claim.Namespace, claim.Name, "size_reduced")
+			gauge(c.bucketCapacity, bucket.Data.Free.Float64(),
+				ns, name, bucket.Name, claim.Namespace, claim.Name, "free")
+			gauge(c.bucketCapacity, bucket.Data.AvailableForUpload.Float64(),
+				ns, name, bucket.Name, claim.Namespace, claim.Name, "available_for_upload")
+		}
+
+		for i := range sys.Pools {
+			pool := &sys.Pools[i]
+			backingStore := ""
+			if stats.BackingStores[pool.Name] {
+				backingStore = pool.Name
+			}
+			healthy := 0.0
+			if pool.Mode == PoolModeOptimal {
+				healthy = 1.0
+			}
+			gauge(c.poolHealthy, healthy, ns, name, pool.Name, backingStore, pool.ResourceType, pool.Mode)
+			for t, v := range storageValues(&pool.Storage) {
+				gauge(c.poolCapacity, v, ns, name, pool.Name, backingStore, t)
+			}
+		}
+	}
+}
+
+func storageValues(storage *nb.StorageInfo) map[string]float64 {
+	return map[string]float64{
+		"total":            storage.Total.Float64(),
+		"free":             storage.Free.Float64(),
+		"used":             storage.Used.Float64(),
+		"used_other":       storage.UsedOther.Float64(),
+		"unavailable_free": storage.UnavailableFree.Float64(),
+		"reserved":         storage.Reserved.Float64(),
+	}
+}
+
+// BucketProvisionerName returns the name of the bucket provisioner of the namespace
+// which is used in the storage classes of the object bucket claims.
+func BucketProvisionerName(namespace string) string {
+	return "noobaa.io/" + namespace + ".bucket"
+}
+
+// CollectStats reads the system stats from the noobaa server and keeps them for the stats metrics.
+// The reader is used to list cluster scoped objects (storage classes and object buckets)
+// which are not available in the namespaced cache of the manager client.
+// Requires an initialized NBClient (see InitNooBaaClient).
+func (s *System) CollectStats(reader client.Client) error {
+
+	log := s.Logger.WithField("func", "CollectStats")
+
+	sys, err := s.NBClient.ReadSystemAPI()
+	if err != nil {
+		return err
+	}
+
+	stats := &SystemStats{
+		Time:          time.Now(),
+		System:        sys,
+		BucketClaims:  map[string]corev1.ObjectReference{},
+		BackingStores: map[string]bool{},
+	}
+
+	backingStores := &nbv1.BackingStoreList{}
+	err = s.Client.List(s.Ctx, &client.ListOptions{Namespace: s.Request.Namespace}, backingStores)
+	if err != nil {
+		log.Warnf("Failed listing backing stores: %v", err)
+	}
+	for i := range backingStores.Items {
+		stats.BackingStores[backingStores.Items[i].Name] = true
+	}
+
+	// find the object bucket claims of buckets that were provisioned by our storage classes
+	storageClasses := &storagev1.StorageClassList{}
+	err = reader.List(s.Ctx, &client.ListOptions{}, storageClasses)
+	if err != nil {
+		log.Warnf("Failed listing storage classes: %v", err)
+	}
+	provisionerStorageClasses := map[string]bool{}
+	for i := range storageClasses.Items {
+		sc := &storageClasses.Items[i]
+		if sc.Provisioner == BucketProvisionerName(s.Request.Namespace) {
+			provisionerStorageClasses[sc.Name] = true
+		}
+	}
+	if len(provisionerStorageClasses) > 0 {
+		objectBuckets := &obAPI.ObjectBucketList{}
+		err = reader.List(s.Ctx, &client.ListOptions{}, objectBuckets)
+		if err != nil {
+			log.Warnf("Failed listing object buckets: %v", err)
+		}
+		for i := range objectBuckets.Items {
+			ob := &objectBuckets.Items[i]
+			if !provisionerStorageClasses[ob.Spec.StorageClassName] ||
+				ob.Spec.ClaimRef == nil ||
+				ob.Spec.Connection == nil ||
+				ob.Spec.Endpoint == nil {
+				continue
+			}
+			stats.BucketClaims[ob.Spec.Endpoint.BucketName] = *ob.Spec.ClaimRef
+		}
+	}
+
+	systemStatsCollector.lock.Lock()
+	systemStatsCollector.stats[s.Request] = stats
+	systemStatsCollector.lock.Unlock()
+
+	log.Infof("Collected stats: buckets %d pools %d accounts %d",
+		len(sys.Buckets), len(sys.Pools), len(sys.Accounts))
+	return nil
+}
+
+// forgetStats removes the stats of a system so it will no longer be exported
+func (s *System) forgetStats() {
+	systemStatsCollector.lock.Lock()
+	defer systemStatsCollector.lock.Unlock()
+	delete(systemStatsCollector.stats, s.Request)
+}
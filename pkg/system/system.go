@@ -7,23 +7,27 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"reflect"
 	"strings"
 	"text/template"
 	"time"
 
 	"github.com/noobaa/noobaa-operator/build/_output/bundle"
-	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1"
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
 	"github.com/noobaa/noobaa-operator/pkg/nb"
 	"github.com/noobaa/noobaa-operator/pkg/util"
 
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
 	dockerref "github.com/docker/distribution/reference"
 	semver "github.com/hashicorp/go-version"
 	"github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -51,6 +55,17 @@ const (
 
 	// AdminAccountEmail is the default email used for admin account
 	AdminAccountEmail = "admin@noobaa.io"
+
+	// OperatorAccountEmail is the email of the account that the operator token belongs to,
+	// which is created with the system and has no login (see CreateOperatorToken)
+	OperatorAccountEmail = "operator@noobaa.io"
+
+	// OperatorRole is the role of the operator token in the system
+	OperatorRole = "operator"
+
+	// ServiceS3AliasName is the name of the s3 service before it was named per system,
+	// which is kept as an alias to the s3 service of the first system in the namespace.
+	ServiceS3AliasName = "s3"
 )
 
 var (
@@ -71,31 +86,78 @@ type System struct {
 	Recorder record.EventRecorder
 	NBClient nb.Client
 
-	NooBaa       *nbv1.NooBaa
-	CoreApp      *appsv1.StatefulSet
-	ServiceMgmt  *corev1.Service
-	ServiceS3    *corev1.Service
-	SecretServer *corev1.Secret
-	SecretOp     *corev1.Secret
-	SecretAdmin  *corev1.Secret
+	// Connected is set when the operator connected to the server in this reconcile (see InitNooBaaClient)
+	Connected bool
+
+	// ConnectErr is set when the operator failed to connect to the server in this reconcile
+	ConnectErr error
+
+	// ClusterReader (optional) is an uncached client for reading cluster scoped objects,
+	// since the client of the operator manager is limited to the watched namespace.
+	ClusterReader client.Client
+
+	// Proxy is the effective proxy configuration of the system (set by ReconcileProxy)
+	Proxy nbv1.ProxySpec
+
+	// ExternalDBSecret is the secret of the external db (set by CheckSpecDB when dbType is external)
+	ExternalDBSecret *corev1.Secret
+
+	// CABundleHash identifies the content of the CABundle config map (set by ReconcileCABundle),
+	// and is empty when there are no extra CA certificates to trust.
+	CABundleHash string
+
+	// TLSHash identifies the certificates of the endpoints (set by ReconcileTLS),
+	// and is empty when all the endpoints serve the self-signed certificates of the server.
+	TLSHash string
+
+	// TargetImage is the image requested by the spec (set by CheckSpecImage),
+	// it becomes the Status.ActualImage when the upgrade flow allows to roll the pods to it.
+	TargetImage string
+
+	NooBaa         *nbv1.NooBaa
+	CoreApp        *appsv1.StatefulSet
+	ServiceMgmt    *corev1.Service
+	ServiceS3      *corev1.Service
+	ServiceS3Alias *corev1.Service
+	SecretServer   *corev1.Secret
+	SecretOp       *corev1.Secret
+	SecretAdmin    *corev1.Secret
+	CABundle       *corev1.ConfigMap
+
+	ServiceMonitor *monitoringv1.ServiceMonitor
+	PrometheusRule *monitoringv1.PrometheusRule
+
+	// ServiceOperatorMetrics and ServiceMonitorOperator scrape the operator metrics of the system
+	ServiceOperatorMetrics *corev1.Service
+	ServiceMonitorOperator *monitoringv1.ServiceMonitor
+	DBBackupCronJob        *batchv1beta1.CronJob
 }
 
 // New initializes a system to be used for loading or reconciling a noobaa system
 func New(req types.NamespacedName, client client.Client, scheme *runtime.Scheme, recorder record.EventRecorder) *System {
 	s := &System{
-		Request:      req,
-		Client:       client,
-		Scheme:       scheme,
-		Recorder:     recorder,
-		Ctx:          context.TODO(),
-		Logger:       logrus.WithFields(logrus.Fields{"ns": req.Namespace, "sys": req.Name}),
-		NooBaa:       util.KubeObject(bundle.File_deploy_crds_noobaa_v1alpha1_noobaa_cr_yaml).(*nbv1.NooBaa),
-		CoreApp:      util.KubeObject(bundle.File_deploy_internal_statefulset_core_yaml).(*appsv1.StatefulSet),
-		ServiceMgmt:  util.KubeObject(bundle.File_deploy_internal_service_mgmt_yaml).(*corev1.Service),
-		ServiceS3:    util.KubeObject(bundle.File_deploy_internal_service_s3_yaml).(*corev1.Service),
-		SecretServer: util.KubeObject(bundle.File_deploy_internal_secret_server_yaml).(*corev1.Secret),
-		SecretOp:     util.KubeObject(bundle.File_deploy_internal_secret_operator_yaml).(*corev1.Secret),
-		SecretAdmin:  util.KubeObject(bundle.File_deploy_internal_secret_admin_yaml).(*corev1.Secret),
+		Request:        req,
+		Client:         client,
+		Scheme:         scheme,
+		Recorder:       recorder,
+		Ctx:            context.TODO(),
+		Logger:         logrus.WithFields(logrus.Fields{"ns": req.Namespace, "sys": req.Name}),
+		NooBaa:         util.KubeObject(bundle.File_deploy_crds_noobaa_v1_noobaa_cr_yaml).(*nbv1.NooBaa),
+		CoreApp:        util.KubeObject(bundle.File_deploy_internal_statefulset_core_yaml).(*appsv1.StatefulSet),
+		ServiceMgmt:    util.KubeObject(bundle.File_deploy_internal_service_mgmt_yaml).(*corev1.Service),
+		ServiceS3:      util.KubeObject(bundle.File_deploy_internal_service_s3_yaml).(*corev1.Service),
+		ServiceS3Alias: util.KubeObject(bundle.File_deploy_internal_service_s3_yaml).(*corev1.Service),
+		SecretServer:   util.KubeObject(bundle.File_deploy_internal_secret_server_yaml).(*corev1.Secret),
+		SecretOp:       util.KubeObject(bundle.File_deploy_internal_secret_operator_yaml).(*corev1.Secret),
+		SecretAdmin:    util.KubeObject(bundle.File_deploy_internal_secret_admin_yaml).(*corev1.Secret),
+		CABundle:       util.KubeObject(bundle.File_deploy_internal_configmap_ca_bundle_yaml).(*corev1.ConfigMap),
+
+		ServiceMonitor:         util.KubeObject(bundle.File_deploy_internal_servicemonitor_mgmt_yaml).(*monitoringv1.ServiceMonitor),
+		PrometheusRule:         util.KubeObject(bundle.File_deploy_internal_prometheus_rules_yaml).(*monitoringv1.PrometheusRule),
+		ServiceOperatorMetrics: util.KubeObject(bundle.File_deploy_internal_service_operator_metrics_yaml).(*corev1.Service),
+		ServiceMonitorOperator: util.KubeObject(bundle.File_deploy_internal_servicemonitor_operator_yaml).(*monitoringv1.ServiceMonitor),
+
+		DBBackupCronJob: util.KubeObject(bundle.File_deploy_internal_cronjob_db_backup_yaml).(*batchv1beta1.CronJob),
 	}
 	SecretResetStringDataFromData(s.SecretOp)
 	SecretResetStringDataFromData(s.SecretAdmin)
@@ -105,23 +167,38 @@ func New(req types.NamespacedName, client client.Client, scheme *runtime.Scheme,
 	s.CoreApp.Namespace = s.Request.Namespace
 	s.ServiceMgmt.Namespace = s.Request.Namespace
 	s.ServiceS3.Namespace = s.Request.Namespace
+	s.ServiceS3Alias.Namespace = s.Request.Namespace
 	s.SecretServer.Namespace = s.Request.Namespace
 	s.SecretOp.Namespace = s.Request.Namespace
 	s.SecretAdmin.Namespace = s.Request.Namespace
+	s.CABundle.Namespace = s.Request.Namespace
+	s.ServiceMonitor.Namespace = s.Request.Namespace
+	s.PrometheusRule.Namespace = s.Request.Namespace
+	s.ServiceOperatorMetrics.Namespace = s.Request.Namespace
+	s.ServiceMonitorOperator.Namespace = s.Request.Namespace
+	s.DBBackupCronJob.Namespace = s.Request.Namespace
 
 	// Set Names
 	s.NooBaa.Name = s.Request.Name
 	s.CoreApp.Name = s.Request.Name + "-core"
 	s.ServiceMgmt.Name = s.Request.Name + "-mgmt"
-	s.ServiceS3.Name = "s3" // TODO: handle collision in namespace
+	s.ServiceS3.Name = s.Request.Name + "-s3"
+	s.ServiceS3Alias.Name = ServiceS3AliasName
 	s.SecretServer.Name = s.Request.Name + "-server"
 	s.SecretOp.Name = s.Request.Name + "-operator"
 	s.SecretAdmin.Name = s.Request.Name + "-admin"
+	s.CABundle.Name = s.Request.Name + "-ca-bundle"
+	s.ServiceMonitor.Name = s.Request.Name + "-mgmt"
+	s.PrometheusRule.Name = s.Request.Name + "-rules"
+	s.ServiceOperatorMetrics.Name = s.Request.Name + "-operator-metrics"
+	s.ServiceMonitorOperator.Name = s.Request.Name + "-operator"
+	s.DBBackupCronJob.Name = s.Request.Name + "-db-backup"
 
 	return s
 }
 
-// Load reads the state of the kubernetes objects of the system
+// Load reads the state of the kubernetes objects of the system and reports the objects status.
+// It is intended for the CLI, and the controllers should use LoadObjects which returns the errors.
 func (s *System) Load() {
 	util.KubeCheck(s.Client, s.NooBaa)
 	util.KubeCheck(s.Client, s.CoreApp)
@@ -134,6 +211,28 @@ func (s *System) Load() {
 	SecretResetStringDataFromData(s.SecretAdmin)
 }
 
+// LoadObjects reads the state of the kubernetes objects of the system.
+// Objects that are not found are left as loaded from the bundle.
+func (s *System) LoadObjects() error {
+	objects := []runtime.Object{
+		s.NooBaa,
+		s.CoreApp,
+		s.ServiceMgmt,
+		s.ServiceS3,
+		s.SecretServer,
+		s.SecretOp,
+		s.SecretAdmin,
+	}
+	for _, obj := range objects {
+		if _, err := util.KubeGet(s.Client, obj); err != nil {
+			return err
+		}
+	}
+	SecretResetStringDataFromData(s.SecretOp)
+	SecretResetStringDataFromData(s.SecretAdmin)
+	return nil
+}
+
 // Reconcile reads that state of the cluster for a System object,
 // and makes changes based on the state read and what is in the System.Spec.
 // The Controller will requeue the Request to be processed again if the returned error is non-nil or
@@ -142,27 +241,54 @@ func (s *System) Reconcile() (reconcile.Result, error) {
 
 	log := s.Logger.WithField("func", "Reconcile")
 	log.Infof("Start ...")
+	start := time.Now()
 
-	util.KubeCheck(s.Client, s.NooBaa)
-	if s.NooBaa.UID == "" {
+	found, err := util.KubeGet(s.Client, s.NooBaa)
+	if err == nil && (!found || s.NooBaa.UID == "") {
 		log.Infof("NooBaa not found or already deleted. Skip reconcile.")
+		s.ForgetMetrics()
+		s.ResetRetryBackoff()
 		return reconcile.Result{}, nil
 	}
 
-	err := CombineErrors(
-		s.ReconcileSystem(),
-		s.UpdateSystemStatus(),
-	)
+	delay := time.Duration(0)
+	if err == nil && s.NooBaa.DeletionTimestamp != nil {
+		err = ClassifyError(s.ReconcileDeletion())
+		if err == nil {
+			log.Infof("✅ Deleted")
+			s.ForgetMetrics()
+			s.ResetRetryBackoff()
+			return reconcile.Result{}, nil
+		}
+		s.SetStandardConditions(err)
+		delay = s.SetNextRetry(err)
+		err = CombineErrors(err, ClassifyError(s.UpdateSystemStatus()))
+	} else if err == nil {
+		err = ClassifyError(s.ReconcileSystem())
+		s.SetStandardConditions(err)
+		delay = s.SetNextRetry(err)
+		err = CombineErrors(err, ClassifyError(s.UpdateSystemStatus()))
+	} else {
+		err = ClassifyError(err)
+	}
+
 	if err == nil {
 		log.Infof("✅ Done")
+		s.ObserveReconcile(start, ReconcileResultSuccess)
 		return reconcile.Result{}, nil
 	}
+	if delay == 0 {
+		// the reconcile itself did not fail (or did not run) so the status does not report this retry
+		delay = s.NextRetryDelay(err)
+	}
 	if !IsPersistentError(err) {
-		log.Warnf("⏳ Temporary Error: %s", err)
-		return reconcile.Result{RequeueAfter: 2 * time.Second}, nil
+		log.Warnf("⏳ Temporary Error: %s (retry in %s)", err, delay.Round(time.Second))
+		s.ObserveReconcile(start, ReconcileResultTemporaryError)
+		return reconcile.Result{RequeueAfter: delay}, nil
 	}
-	log.Errorf("❌ Persistent Error: %s", err)
-	return reconcile.Result{}, nil
+	log.Errorf("❌ Persistent Error: %s (resync in %s)", err, delay.Round(time.Second))
+	s.ObserveReconcile(start, ReconcileResultPersistentError)
+	return reconcile.Result{RequeueAfter: delay}, nil
 }
 
 // ReconcileSystem runs the reconcile flow and populates System.Status.
@@ -170,33 +296,82 @@ func (s *System) ReconcileSystem() error {
 
 	s.SetPhase(nbv1.SystemPhaseVerifying)
 
+	if err := s.ReconcileFinalizer(); err != nil {
+		return err
+	}
 	if err := s.CheckSpecImage(); err != nil {
 		return err
 	}
+	if err := s.CheckSpecDB(); err != nil {
+		return err
+	}
+	if err := s.CheckSpecKMS(); err != nil {
+		return err
+	}
+	if err := s.CheckSpecTLS(); err != nil {
+		return err
+	}
+	if err := s.ReconcileUpgrade(); err != nil {
+		return err
+	}
 
 	s.SetPhase(nbv1.SystemPhaseCreating)
 
 	if err := s.ReconcileSecretServer(); err != nil {
 		return err
 	}
+	if err := s.ReconcileKMS(); err != nil {
+		return err
+	}
+	if err := s.ReconcileProxy(); err != nil {
+		return err
+	}
+	if err := s.ReconcileCABundle(); err != nil {
+		return err
+	}
+	if err := s.ReconcileTLS(); err != nil {
+		return err
+	}
 	if err := s.ReconcileObject(s.CoreApp, s.SetDesiredCoreApp); err != nil {
 		return err
 	}
+	if err := s.ReconcileDBVolume(); err != nil {
+		return err
+	}
 	if err := s.ReconcileObject(s.ServiceMgmt, s.SetDesiredServiceMgmt); err != nil {
 		return err
 	}
 	if err := s.ReconcileObject(s.ServiceS3, s.SetDesiredServiceS3); err != nil {
 		return err
 	}
+	if err := s.ReconcileServiceS3Alias(); err != nil {
+		return err
+	}
+	if err := s.ReconcileMonitoring(); err != nil {
+		return err
+	}
+	if err := s.ReconcileDBBackup(); err != nil {
+		return err
+	}
 
 	s.CheckServiceStatus(s.ServiceMgmt, &s.NooBaa.Status.Services.ServiceMgmt, "mgmt-https")
 	s.CheckServiceStatus(s.ServiceS3, &s.NooBaa.Status.Services.ServiceS3, "s3-https")
 
 	s.SetPhase(nbv1.SystemPhaseWaitingToConnect)
 
+	if s.IsRestoringDB() {
+		return fmt.Errorf("waiting for the db restore to complete")
+	}
+	// the auth token is needed to complete an upgrade, which is before ReconcileSecretOp
+	if err := s.LoadSecretOp(); err != nil {
+		return err
+	}
 	if err := s.InitNooBaaClient(); err != nil {
 		return err
 	}
+	if err := s.CompleteUpgrade(); err != nil {
+		return err
+	}
 
 	s.SetPhase(nbv1.SystemPhaseConfiguring)
 
@@ -208,25 +383,43 @@ func (s *System) ReconcileSystem() error {
 		return err
 	}
 
+	if err := s.ReconcileRotation(); err != nil {
+		return err
+	}
+
 	s.SetPhase(nbv1.SystemPhaseReady)
 
 	return s.Complete()
 }
 
+// LoadSecretOp reads the operator secret with the auth token of the operator,
+// so that the client can be initialized with the token before ReconcileSecretOp.
+func (s *System) LoadSecretOp() error {
+	if _, err := util.KubeGet(s.Client, s.SecretOp); err != nil {
+		return err
+	}
+	SecretResetStringDataFromData(s.SecretOp)
+	return nil
+}
+
 // ReconcileSecretServer creates a secret needed for the server pod
 func (s *System) ReconcileSecretServer() error {
-	util.KubeCheck(s.Client, s.SecretServer)
+	if _, err := util.KubeGet(s.Client, s.SecretServer); err != nil {
+		return err
+	}
 	SecretResetStringDataFromData(s.SecretServer)
 
 	if s.SecretServer.StringData["jwt"] == "" {
 		s.SecretServer.StringData["jwt"] = randomBase64(16)
 	}
 	if s.SecretServer.StringData["server_secret"] == "" {
-		s.SecretServer.StringData["server_secret"] = randomHex(4)
+		s.SecretServer.StringData["server_secret"] = randomHex(16)
 	}
-	s.Own(s.SecretServer)
-	util.KubeCreateSkipExisting(s.Client, s.SecretServer)
-	return nil
+	if err := s.Own(s.SecretServer); err != nil {
+		return err
+	}
+	_, err := util.KubeCreateIfMissing(s.Client, s.SecretServer)
+	return err
 }
 
 // SetDesiredCoreApp updates the CoreApp as desired for reconciling
@@ -239,19 +432,28 @@ func (s *System) SetDesiredCoreApp() {
 
 	podSpec := &s.CoreApp.Spec.Template.Spec
 	podSpec.ServiceAccountName = "noobaa-operator" // TODO do we use the same SA?
+	// images are set by container name (and not by the NOOBAA_IMAGE placeholder)
+	// so that an existing statefulset is also rolled to a new ActualImage.
 	for i := range podSpec.InitContainers {
-		if podSpec.InitContainers[i].Image == "NOOBAA_IMAGE" {
+		if podSpec.InitContainers[i].Name == "init-mongo" {
 			podSpec.InitContainers[i].Image = s.NooBaa.Status.ActualImage
 		}
 	}
 	for i := range podSpec.Containers {
-		if podSpec.Containers[i].Image == "NOOBAA_IMAGE" {
-			podSpec.Containers[i].Image = s.NooBaa.Status.ActualImage
-		} else if podSpec.Containers[i].Image == "MONGO_IMAGE" {
+		c := &podSpec.Containers[i]
+		if c.Name == "noobaa-server" {
+			c.Image = s.NooBaa.Status.ActualImage
+			if s.NooBaa.Spec.CoreResources != nil {
+				c.Resources = *s.NooBaa.Spec.CoreResources
+			}
+		} else if c.Name == "mongodb" {
 			if s.NooBaa.Spec.MongoImage == nil {
-				podSpec.Containers[i].Image = MongoImage
+				c.Image = MongoImage
 			} else {
-				podSpec.Containers[i].Image = *s.NooBaa.Spec.MongoImage
+				c.Image = *s.NooBaa.Spec.MongoImage
+			}
+			if s.NooBaa.Spec.DBResources != nil {
+				c.Resources = *s.NooBaa.Spec.DBResources
 			}
 		}
 	}
@@ -262,10 +464,26 @@ func (s *System) SetDesiredCoreApp() {
 		podSpec.ImagePullSecrets =
 			[]corev1.LocalObjectReference{*s.NooBaa.Spec.ImagePullSecret}
 	}
+	s.SetDesiredPodScheduling(podSpec)
+	if s.NooBaa.Spec.DBType == nbv1.DBTypeExternal {
+		s.SetDesiredExternalDB()
+	}
+	s.SetDesiredKMS()
+	s.SetDesiredProxy()
+	s.SetDesiredCABundleMount()
+	s.SetDesiredTLS()
+	s.SetDesiredRestoringDB()
+
 	for i := range s.CoreApp.Spec.VolumeClaimTemplates {
 		pvc := &s.CoreApp.Spec.VolumeClaimTemplates[i]
 		pvc.Spec.StorageClassName = s.NooBaa.Spec.StorageClassName
 
+		// volumeClaimTemplates are immutable so the db volume resources are set only on create,
+		// and ReconcileDBVolume expands the existing PVC.
+		if pvc.Name == "mongo-datadir" && s.NooBaa.Spec.DBVolumeResources != nil && s.CoreApp.UID == "" {
+			pvc.Spec.Resources = *s.NooBaa.Spec.DBVolumeResources
+		}
+
 		// TODO we want to own the PVC's by NooBaa system but get errors on openshift:
 		//   Warning  FailedCreate  56s  statefulset-controller
 		//   create Pod noobaa-core-0 in StatefulSet noobaa-core failed error:
@@ -278,18 +496,238 @@ func (s *System) SetDesiredCoreApp() {
 	}
 }
 
+// SetDesiredPodScheduling sets the scheduling fields of a pod spec from the system spec.
+// It should be called for every pod template that the operator renders for the system,
+// and overrides any manual changes to these fields.
+func (s *System) SetDesiredPodScheduling(podSpec *corev1.PodSpec) {
+	podSpec.Tolerations = s.NooBaa.Spec.Tolerations
+	podSpec.Affinity = s.NooBaa.Spec.Affinity
+	podSpec.NodeSelector = s.NooBaa.Spec.NodeSelector
+	podSpec.PriorityClassName = s.NooBaa.Spec.PriorityClassName
+}
+
+// ExternalDBCAFile is the path of the CA bundle of the external db in the server container
+const ExternalDBCAFile = "/etc/mongodb-tls/ca.crt"
+
+// SetDesiredExternalDB removes the embedded mongodb from the CoreApp
+// and configures the server container to connect to the external db url.
+// When the external db secret has a CA bundle, the url is extended with the tls options to verify the db with the CA.
+// The url is passed as a secret reference and extended with a dependent env var, so its credentials are not rendered to the pod spec.
+func (s *System) SetDesiredExternalDB() {
+	podSpec := &s.CoreApp.Spec.Template.Spec
+
+	initContainers := []corev1.Container{}
+	for _, c := range podSpec.InitContainers {
+		if c.Name != "init-mongo" {
+			initContainers = append(initContainers, c)
+		}
+	}
+	podSpec.InitContainers = initContainers
+
+	containers := []corev1.Container{}
+	for _, c := range podSpec.Containers {
+		if c.Name != "mongodb" {
+			containers = append(containers, c)
+		}
+	}
+	podSpec.Containers = containers
+
+	// volumeClaimTemplates are immutable so we can only remove the db pvc on create.
+	// CheckSpecDB verifies that an existing statefulset already matches the dbType.
+	if s.CoreApp.UID == "" {
+		pvcs := []corev1.PersistentVolumeClaim{}
+		for _, pvc := range s.CoreApp.Spec.VolumeClaimTemplates {
+			if pvc.Name != "mongo-datadir" {
+				pvcs = append(pvcs, pvc)
+			}
+		}
+		s.CoreApp.Spec.VolumeClaimTemplates = pvcs
+	}
+
+	optional := true
+	setVolume(podSpec, corev1.Volume{
+		Name: "mongodb-tls",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: s.NooBaa.Spec.ExternalDBSecret.Name,
+				Items:      []corev1.KeyToPath{{Key: "ca.crt", Path: "ca.crt"}},
+				Optional:   &optional,
+			},
+		},
+	})
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		if c.Name != "noobaa-server" {
+			continue
+		}
+		dbURL := corev1.EnvVar{
+			Name: "MONGODB_URL",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: *s.NooBaa.Spec.ExternalDBSecret,
+					Key:                  "db_url",
+				},
+			},
+		}
+		if tlsOptions := s.ExternalDBTLSOptions(); tlsOptions != "" {
+			// env vars can only reference the vars that are defined before them
+			removeEnv(c, "MONGODB_URL")
+			dbURL.Name = "EXTERNAL_DB_URL"
+			setEnv(c, dbURL)
+			dbURL = corev1.EnvVar{Name: "MONGODB_URL", Value: "$(EXTERNAL_DB_URL)" + tlsOptions}
+		} else {
+			removeEnv(c, "EXTERNAL_DB_URL")
+		}
+		setEnv(c, dbURL)
+		setVolumeMount(c, corev1.VolumeMount{
+			Name:      "mongodb-tls",
+			MountPath: "/etc/mongodb-tls",
+			ReadOnly:  true,
+		})
+	}
+}
+
+// ReconcileDBVolume expands the PVC of the db volume when Spec.DBVolumeResources requests more storage.
+// Kubernetes does not support shrinking a PVC so smaller requests are only reported.
+// Failures are reported as events but do not fail the reconcile since the system can keep running.
+func (s *System) ReconcileDBVolume() error {
+
+	log := s.Logger.WithField("func", "ReconcileDBVolume")
+
+	if s.NooBaa.Spec.DBVolumeResources == nil || s.NooBaa.Spec.DBType == nbv1.DBTypeExternal {
+		return nil
+	}
+	desired, ok := s.NooBaa.Spec.DBVolumeResources.Requests[corev1.ResourceStorage]
+	if !ok {
+		return nil
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := s.GetObject(s.DBVolumeClaimName(), pvc)
+	if errors.IsNotFound(err) {
+		// the statefulset will create the pvc from the template
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	current := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if desired.Cmp(current) == 0 {
+		return nil
+	}
+	if desired.Cmp(current) < 0 {
+		log.Warnf("Cannot shrink db volume %s from %s to %s", pvc.Name, current.String(), desired.String())
+		if s.Recorder != nil {
+			s.Recorder.Eventf(s.NooBaa, corev1.EventTypeWarning, "DBVolumeShrink",
+				`Cannot shrink db volume "%s" from %s to %s`, pvc.Name, current.String(), desired.String())
+		}
+		return nil
+	}
+
+	log.Infof("Expanding db volume %s from %s to %s", pvc.Name, current.String(), desired.String())
+	if pvc.Spec.Resources.Requests == nil {
+		pvc.Spec.Resources.Requests = corev1.ResourceList{}
+	}
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = desired
+	err = s.Client.Update(s.Ctx, pvc)
+	if err != nil {
+		log.Warnf("Failed expanding db volume %s: %s", pvc.Name, err)
+		if s.Recorder != nil {
+			s.Recorder.Eventf(s.NooBaa, corev1.EventTypeWarning, "DBVolumeExpandFailed",
+				`Failed expanding db volume "%s" to %s (does the storage class allow volume expansion?): %s`,
+				pvc.Name, desired.String(), err)
+		}
+		return nil
+	}
+	if s.Recorder != nil {
+		s.Recorder.Eventf(s.NooBaa, corev1.EventTypeNormal, "DBVolumeExpanded",
+			`Expanding db volume "%s" from %s to %s`, pvc.Name, current.String(), desired.String())
+	}
+	return nil
+}
+
+// DBVolumeClaimName returns the name of the PVC that the core statefulset creates for the db volume
+func (s *System) DBVolumeClaimName() string {
+	return "mongo-datadir-" + s.CoreApp.Name + "-0"
+}
+
 // SetDesiredServiceMgmt updates the ServiceMgmt as desired for reconciling
 func (s *System) SetDesiredServiceMgmt() {
+	if s.ServiceMgmt.Labels == nil {
+		s.ServiceMgmt.Labels = map[string]string{}
+	}
+	s.ServiceMgmt.Labels["noobaa-mgmt-svc"] = s.Request.Name
 	s.ServiceMgmt.Spec.Selector["noobaa-mgmt"] = s.Request.Name
+	SetDesiredServiceSpec(s.ServiceMgmt, &s.NooBaa.Spec.Services.Mgmt)
 }
 
 // SetDesiredServiceS3 updates the ServiceS3 as desired for reconciling
 func (s *System) SetDesiredServiceS3() {
 	s.ServiceS3.Spec.Selector["noobaa-s3"] = s.Request.Name
+	SetDesiredServiceSpec(s.ServiceS3, &s.NooBaa.Spec.Services.S3)
+}
+
+// ReconcileServiceS3Alias reconciles the legacy "s3" service as an alias to the s3 service of the system.
+// The alias is created as a ClusterIP service only if the name is free in the namespace,
+// and an existing alias that is owned by the system only gets its selector updated,
+// so an existing LoadBalancer service keeps its external address.
+func (s *System) ReconcileServiceS3Alias() error {
+
+	log := s.Logger.WithField("func", "ReconcileServiceS3Alias")
+
+	existing := &corev1.Service{}
+	err := s.GetObject(s.ServiceS3Alias.Name, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && !metav1.IsControlledBy(existing, s.NooBaa) {
+		log.Infof("Service %s is used by another owner, skip alias", existing.Name)
+		return nil
+	}
+
+	return s.ReconcileObject(s.ServiceS3Alias, func() {
+		// keep the type of an existing alias (an old LoadBalancer keeps its external address)
+		if s.ServiceS3Alias.UID == "" {
+			s.ServiceS3Alias.Spec.Type = corev1.ServiceTypeClusterIP
+		} else {
+			s.ServiceS3Alias.Spec.Type = existing.Spec.Type
+		}
+		s.ServiceS3Alias.Spec.Selector["noobaa-s3"] = s.Request.Name
+	})
+}
+
+// SetDesiredServiceSpec applies the service options from the system spec to a service.
+// The annotations from the spec are added to the annotations of the template,
+// and other annotations that were added to the live service are removed as a drift.
+func SetDesiredServiceSpec(srv *corev1.Service, spec *nbv1.ServiceSpec) {
+	srv.Spec.Type = corev1.ServiceTypeLoadBalancer
+	if spec.Type != "" {
+		srv.Spec.Type = spec.Type
+	}
+	if srv.Spec.Type == corev1.ServiceTypeClusterIP {
+		// node ports are not allowed when changing the type to ClusterIP
+		for i := range srv.Spec.Ports {
+			srv.Spec.Ports[i].NodePort = 0
+		}
+	}
+	if srv.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		srv.Spec.LoadBalancerSourceRanges = spec.LoadBalancerSourceRanges
+	} else {
+		srv.Spec.LoadBalancerSourceRanges = nil
+	}
+	if len(spec.Annotations) > 0 {
+		if srv.Annotations == nil {
+			srv.Annotations = map[string]string{}
+		}
+		for key, value := range spec.Annotations {
+			srv.Annotations[key] = value
+		}
+	}
 }
 
 // CheckSpecImage checks the System.Spec.Image property,
-// and sets System.Status.ActualImage
+// and sets System.TargetImage
 func (s *System) CheckSpecImage() error {
 
 	log := s.Logger.WithField("func", "CheckSpecImage")
@@ -362,8 +800,88 @@ func (s *System) CheckSpecImage() error {
 		}
 	}
 
-	// Set ActualImage to be updated in the noobaa status
-	s.NooBaa.Status.ActualImage = specImage
+	// Set TargetImage to be checked by ReconcileUpgrade
+	s.TargetImage = specImage
+	return nil
+}
+
+// CheckSpecDB checks the System.Spec.DBType and ExternalDBSecret properties
+func (s *System) CheckSpecDB() error {
+
+	log := s.Logger.WithField("func", "CheckSpecDB")
+
+	dbType := s.NooBaa.Spec.DBType
+	if dbType == "" {
+		dbType = nbv1.DBTypeMongoDB
+	}
+
+	reject := func(reason string, format string, args ...interface{}) error {
+		msg := fmt.Sprintf(format, args...)
+		log.Errorf("%s", msg)
+		if s.Recorder != nil {
+			s.Recorder.Event(s.NooBaa, corev1.EventTypeWarning, reason, msg)
+		}
+		s.SetPhase(nbv1.SystemPhaseRejected)
+		return NewPersistentError(fmt.Errorf("%s", msg))
+	}
+
+	switch dbType {
+	case nbv1.DBTypeMongoDB:
+	case nbv1.DBTypeExternal:
+		if s.NooBaa.Spec.ExternalDBSecret == nil || s.NooBaa.Spec.ExternalDBSecret.Name == "" {
+			return reject("BadDBSpec", `Missing externalDBSecret for dbType "%s"`, dbType)
+		}
+		if s.NooBaa.Spec.DBBackup != nil {
+			return reject("BadDBSpec", `DB backup is not supported for dbType "%s"`, dbType)
+		}
+	default:
+		return reject("BadDBSpec", `Invalid dbType "%s"`, dbType)
+	}
+
+	if s.NooBaa.Spec.DBBackup != nil {
+		if s.NooBaa.Spec.DBBackup.Schedule == "" || s.NooBaa.Spec.DBBackup.VolumeClaimName == "" {
+			return reject("BadDBSpec", `DB backup requires schedule and volumeClaimName`)
+		}
+	}
+
+	// the db type cannot be changed once the statefulset was created with/without the db pvc
+	sts := &appsv1.StatefulSet{}
+	err := s.GetObject(s.CoreApp.Name, sts)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		hasDBPVC := false
+		for _, pvc := range sts.Spec.VolumeClaimTemplates {
+			if pvc.Name == "mongo-datadir" {
+				hasDBPVC = true
+			}
+		}
+		if hasDBPVC != (dbType == nbv1.DBTypeMongoDB) {
+			return reject("BadDBSpec", `Changing dbType of an existing system to "%s" is not supported`, dbType)
+		}
+	}
+
+	if dbType == nbv1.DBTypeExternal {
+		secret := &corev1.Secret{}
+		err := s.GetObject(s.NooBaa.Spec.ExternalDBSecret.Name, secret)
+		if err != nil {
+			if errors.IsNotFound(err) && s.Recorder != nil {
+				s.Recorder.Eventf(s.NooBaa, corev1.EventTypeWarning, "MissingDBSecret",
+					`Waiting for external db secret "%s"`, s.NooBaa.Spec.ExternalDBSecret.Name)
+			}
+			return err
+		}
+		if len(secret.Data["db_url"]) == 0 {
+			if s.Recorder != nil {
+				s.Recorder.Eventf(s.NooBaa, corev1.EventTypeWarning, "MissingDBSecret",
+					`External db secret "%s" is missing the "db_url" key`, secret.Name)
+			}
+			return fmt.Errorf(`external db secret "%s" is missing the "db_url" key`, secret.Name)
+		}
+		s.ExternalDBSecret = secret
+	}
+
 	return nil
 }
 
@@ -389,7 +907,7 @@ func (s *System) CheckServiceStatus(srv *corev1.Service, status *nbv1.ServiceSta
 	if err == nil {
 		for _, pod := range pods.Items {
 			if pod.Status.Phase == corev1.PodRunning {
-				if pod.Status.HostIP != "" {
+				if pod.Status.HostIP != "" && servicePort.NodePort != 0 {
 					status.NodePorts = append(
 						status.NodePorts,
 						fmt.Sprintf("%s://%s:%d", proto, pod.Status.HostIP, servicePort.NodePort),
@@ -451,6 +969,18 @@ func (s *System) CheckServiceStatus(srv *corev1.Service, status *nbv1.ServiceSta
 // InitNooBaaClient initializes the noobaa client for making calls to the server.
 func (s *System) InitNooBaaClient() error {
 
+	if s.ServiceMgmt.Spec.Type == corev1.ServiceTypeClusterIP {
+		// without node ports we use the service address which requires the operator to run in the cluster
+		s.NBClient = nb.NewClient(&nb.APIRouterServicePort{
+			ServiceMgmt: s.ServiceMgmt,
+		})
+		s.NBClient.SetAuthToken(s.SecretOp.StringData["auth_token"])
+		_, err := s.NBClient.ReadAuthAPI()
+		s.Connected = err == nil
+		s.ConnectErr = err
+		return err
+	}
+
 	if len(s.NooBaa.Status.Services.ServiceMgmt.NodePorts) == 0 {
 		return fmt.Errorf("core pod port not ready yet")
 	}
@@ -463,6 +993,8 @@ func (s *System) InitNooBaaClient() error {
 	})
 	s.NBClient.SetAuthToken(s.SecretOp.StringData["auth_token"])
 	_, err := s.NBClient.ReadAuthAPI()
+	s.Connected = err == nil
+	s.ConnectErr = err
 	return err
 
 	// if len(s.NooBaa.Status.Services.ServiceMgmt.PodPorts) != 0 {
@@ -478,11 +1010,18 @@ func (s *System) InitNooBaaClient() error {
 
 }
 
-// ReconcileSecretOp creates a new system in the noobaa server if not created yet.
+// ReconcileSecretOp creates a new system in the noobaa server if not created yet,
+// and keeps the operator token in the operator secret.
+// If the operator secret was lost it recovers a token by authenticating with
+// the credentials of the admin secret or the recovery secret from the spec,
+// and sets the CredentialsLost condition if none of these works.
 func (s *System) ReconcileSecretOp() error {
 
-	// log := s.Logger.WithName("ReconcileSecretOp")
-	util.KubeCheck(s.Client, s.SecretOp)
+	log := s.Logger.WithField("func", "ReconcileSecretOp")
+
+	if _, err := util.KubeGet(s.Client, s.SecretOp); err != nil {
+		return err
+	}
 	SecretResetStringDataFromData(s.SecretOp)
 
 	if s.SecretOp.StringData["auth_token"] != "" {
@@ -495,90 +1034,247 @@ func (s *System) ReconcileSecretOp() error {
 
 	if s.SecretOp.StringData["password"] == "" {
 		s.SecretOp.StringData["password"] = randomBase64(16)
-		s.Own(s.SecretOp)
+		if err := s.Own(s.SecretOp); err != nil {
+			return err
+		}
 		err := s.Client.Create(s.Ctx, s.SecretOp)
-		if err != nil {
+		if err != nil && !errors.IsAlreadyExists(err) {
 			return err
 		}
+		if errors.IsAlreadyExists(err) {
+			if err := s.Client.Update(s.Ctx, s.SecretOp); err != nil {
+				return err
+			}
+		}
+		SecretResetStringDataFromData(s.SecretOp)
 	}
 
-	res, err := s.NBClient.CreateAuthAPI(nb.CreateAuthParams{
-		System:   s.Request.Name,
-		Role:     "admin",
+	// try to create the system first, which fails if it was already created,
+	// and then try to authenticate with every credentials we know.
+	res, createErr := s.NBClient.CreateSystemAPI(nb.CreateSystemParams{
+		Name:     s.Request.Name,
 		Email:    s.SecretOp.StringData["email"],
 		Password: s.SecretOp.StringData["password"],
 	})
-	if err == nil {
-		// TODO this recovery flow does not allow us to get OperatorToken like CreateSystem
-		s.SecretOp.StringData["auth_token"] = res.Token
+	if createErr == nil {
+		log.Infof("✅ Created system in the server")
+		s.SecretOp.StringData["auth_token"] = res.OperatorToken
+		if res.OperatorToken == "" {
+			// the admin token is only used to create the token of the operator account
+			token, err := s.CreateOperatorToken(res.Token)
+			if err != nil {
+				return err
+			}
+			s.SecretOp.StringData["auth_token"] = token
+		}
 	} else {
-		res, err := s.NBClient.CreateSystemAPI(nb.CreateSystemParams{
-			Name:     s.Request.Name,
-			Email:    s.SecretOp.StringData["email"],
-			Password: s.SecretOp.StringData["password"],
-		})
+		log.Infof("Create system failed (%s), authenticating to the existing system", createErr)
+		token, err := s.RecoverOperatorToken()
 		if err != nil {
-			return err
+			msg := fmt.Sprintf("Cannot authenticate to the system with the operator, admin or recovery secrets: %s", err)
+			log.Errorf("❌ %s", msg)
+			s.SetCondition(nbv1.ConditionTypeCredentialsLost, nbv1.ConditionTrue, "AuthenticationFailed", msg)
+			if s.Recorder != nil {
+				s.Recorder.Event(s.NooBaa, corev1.EventTypeWarning, "CredentialsLost",
+					msg+" - set spec.recoverySecret with the email and password of an admin account")
+			}
+			return NewPersistentError(fmt.Errorf("%s", msg))
 		}
-		// TODO use res.OperatorToken after https://github.com/noobaa/noobaa-core/issues/5635
-		s.SecretOp.StringData["auth_token"] = res.Token
+		s.SecretOp.StringData["auth_token"] = token
 	}
+
+	s.SetCondition(nbv1.ConditionTypeCredentialsLost, nbv1.ConditionFalse, "Authenticated", "")
 	s.NBClient.SetAuthToken(s.SecretOp.StringData["auth_token"])
 	return s.Client.Update(s.Ctx, s.SecretOp)
 }
 
-// ReconcileSecretAdmin creates the admin secret
+// RecoverOperatorToken authenticates to the existing system with the credentials
+// of the operator secret, the admin secret, the pending admin password of an interrupted rotation,
+// or the recovery secret from the spec (in that order),
+// and returns a new operator token (see CreateOperatorToken) which is authorized by the admin token.
+// The credentials that worked are copied to the operator secret.
+func (s *System) RecoverOperatorToken() (string, error) {
+
+	log := s.Logger.WithField("func", "RecoverOperatorToken")
+
+	type credentials struct {
+		source   string
+		email    string
+		password string
+	}
+	candidates := []credentials{{
+		source:   "secret/" + s.SecretOp.Name,
+		email:    s.SecretOp.StringData["email"],
+		password: s.SecretOp.StringData["password"],
+	}}
+
+	secretAdmin := &corev1.Secret{}
+	if err := s.GetObject(s.SecretAdmin.Name, secretAdmin); err == nil {
+		candidates = append(candidates, credentials{
+			source:   "secret/" + secretAdmin.Name,
+			email:    string(secretAdmin.Data["email"]),
+			password: string(secretAdmin.Data["password"]),
+		})
+	}
+
+	// an interrupted password rotation might have changed the admin password (see RotateAdminPassword)
+	if pending := s.SecretOp.StringData["pending_password"]; pending != "" {
+		candidates = append(candidates, credentials{
+			source:   "secret/" + s.SecretOp.Name + " (pending_password)",
+			email:    AdminAccountEmail,
+			password: pending,
+		})
+	}
+
+	if s.NooBaa.Spec.RecoverySecret != nil {
+		secret := &corev1.Secret{}
+		if err := s.GetObject(s.NooBaa.Spec.RecoverySecret.Name, secret); err != nil {
+			log.Warnf("Failed getting recovery secret %s: %s", s.NooBaa.Spec.RecoverySecret.Name, err)
+		} else {
+			candidates = append(candidates, credentials{
+				source:   "secret/" + secret.Name,
+				email:    string(secret.Data["email"]),
+				password: string(secret.Data["password"]),
+			})
+		}
+	}
+
+	errs := []string{}
+	for _, c := range candidates {
+		if c.email == "" || c.password == "" {
+			continue
+		}
+		res, err := s.NBClient.CreateAuthAPI(nb.CreateAuthParams{
+			System:   s.Request.Name,
+			Role:     "admin",
+			Email:    c.email,
+			Password: c.password,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", c.source, err))
+			continue
+		}
+		log.Infof("✅ Authenticated with the credentials of %s", c.source)
+		token, err := s.CreateOperatorToken(res.Token)
+		if err != nil {
+			return "", err
+		}
+		if c.source != "secret/"+s.SecretOp.Name && s.Recorder != nil {
+			s.Recorder.Eventf(s.NooBaa, corev1.EventTypeNormal, "CredentialsRecovered",
+				`Recovered the operator credentials using %s`, c.source)
+		}
+		s.SecretOp.StringData["email"] = c.email
+		s.SecretOp.StringData["password"] = c.password
+		return token, nil
+	}
+	return "", fmt.Errorf("%s", strings.Join(errs, ", "))
+}
+
+// CreateOperatorToken uses an admin token to create a token with the operator role
+// for the operator account, so that the admin token is never kept in the operator secret.
+// The operator account is restored if it was deleted from the system.
+// The client is left with the admin token, and the caller sets the returned token.
+func (s *System) CreateOperatorToken(adminToken string) (string, error) {
+
+	log := s.Logger.WithField("func", "CreateOperatorToken")
+
+	s.NBClient.SetAuthToken(adminToken)
+	params := nb.CreateAuthParams{
+		System: s.Request.Name,
+		Role:   OperatorRole,
+		Email:  OperatorAccountEmail,
+	}
+	res, err := s.NBClient.CreateAuthAPI(params)
+	if nbErr, ok := err.(*nb.RPCError); ok && nbErr.RPCCode == "NO_SUCH_ACCOUNT" {
+		log.Infof("Operator account %s not found, restoring it", OperatorAccountEmail)
+		_, err = s.NBClient.CreateAccountAPI(nb.CreateAccountParams{
+			Name:     "operator",
+			Email:    OperatorAccountEmail,
+			HasLogin: false,
+			S3Access: false,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to restore the operator account: %s", err)
+		}
+		res, err = s.NBClient.CreateAuthAPI(params)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create the operator token: %s", err)
+	}
+	return res.Token, nil
+}
+
+// ReconcileSecretAdmin creates the admin secret and keeps it updated with the current
+// admin password from the operator secret and the access keys of the admin account,
+// so that rotated credentials (see ReconcileRotation) are propagated to the secret.
 func (s *System) ReconcileSecretAdmin() error {
 
 	log := s.Logger.WithField("func", "ReconcileSecretAdmin")
 
-	util.KubeCheck(s.Client, s.SecretAdmin)
-	SecretResetStringDataFromData(s.SecretAdmin)
-
 	ns := s.Request.Namespace
 	name := s.Request.Name
 	secretAdminName := name + "-admin"
 
-	s.SecretAdmin = &corev1.Secret{}
-	err := s.GetObject(secretAdminName, s.SecretAdmin)
-	if err == nil {
-		return nil
-	}
-	if !errors.IsNotFound(err) {
+	secretAdmin := &corev1.Secret{}
+	err := s.GetObject(secretAdminName, secretAdmin)
+	if err != nil && !errors.IsNotFound(err) {
 		log.Errorf("Failed getting admin secret: %v", err)
 		return err
 	}
+	exists := err == nil
 
-	s.SecretAdmin = &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace: ns,
-			Name:      secretAdminName,
-			Labels:    map[string]string{"app": "noobaa"},
-		},
-		Type: corev1.SecretTypeOpaque,
-		StringData: map[string]string{
-			"system":   name,
-			"email":    AdminAccountEmail,
-			"password": string(s.SecretOp.Data["password"]),
-		},
+	desired := map[string]string{
+		"system": name,
+		"email":  AdminAccountEmail,
+	}
+	if exists {
+		for key, val := range secretAdmin.Data {
+			desired[key] = string(val)
+		}
+	}
+	if s.SecretOp.StringData["email"] == AdminAccountEmail || !exists {
+		desired["password"] = s.SecretOp.StringData["password"]
 	}
 
-	log.Infof("listing accounts")
-	res, err := s.NBClient.ListAccountsAPI()
+	account, err := s.NBClient.ReadAccountAPI(nb.ReadAccountParams{Email: AdminAccountEmail})
 	if err != nil {
 		return err
 	}
-	for _, account := range res.Accounts {
-		if account.Email == AdminAccountEmail {
-			if len(account.AccessKeys) > 0 {
-				s.SecretAdmin.StringData["AWS_ACCESS_KEY_ID"] = account.AccessKeys[0].AccessKey
-				s.SecretAdmin.StringData["AWS_SECRET_ACCESS_KEY"] = account.AccessKeys[0].SecretKey
-			}
+	if len(account.AccessKeys) > 0 {
+		desired["AWS_ACCESS_KEY_ID"] = account.AccessKeys[0].AccessKey
+		desired["AWS_SECRET_ACCESS_KEY"] = account.AccessKeys[0].SecretKey
+	}
+
+	if !exists {
+		s.SecretAdmin = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ns,
+				Name:      secretAdminName,
+				Labels:    map[string]string{"app": "noobaa"},
+			},
+			Type:       corev1.SecretTypeOpaque,
+			StringData: desired,
+		}
+		if err := s.Own(s.SecretAdmin); err != nil {
+			return err
 		}
+		return s.Client.Create(s.Ctx, s.SecretAdmin)
 	}
 
-	s.Own(s.SecretAdmin)
-	return s.Client.Create(s.Ctx, s.SecretAdmin)
+	s.SecretAdmin = secretAdmin
+	changed := false
+	for key, val := range desired {
+		if string(secretAdmin.Data[key]) != val {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	log.Infof("Updating admin secret with the current credentials")
+	SecretResetStringDataFromData(s.SecretAdmin)
+	s.SecretAdmin.StringData = desired
+	return s.Client.Update(s.Ctx, s.SecretAdmin)
 }
 
 var readmeTemplate = template.Must(template.New("NooBaaSystem.Status.Readme").Parse(`
@@ -609,25 +1305,62 @@ var readmeTemplate = template.Must(template.New("NooBaaSystem.Status.Readme").Pa
 
 `))
 
-// SetPhase updates the status phase and conditions
+// SetPhase updates the status phase and conditions, and records the phase transition in the metrics
 func (s *System) SetPhase(phase nbv1.SystemPhase) {
 	s.Logger.Warnf("GGG SetPhase %s", phase)
 	s.NooBaa.Status.Phase = phase
-	if s.NooBaa.Status.Conditions == nil || len(s.NooBaa.Status.Conditions) == 0 {
-		s.NooBaa.Status.Conditions = []nbv1.SystemCondition{{
+	var phaseCond *nbv1.SystemCondition
+	for i := range s.NooBaa.Status.Conditions {
+		if s.NooBaa.Status.Conditions[i].Type == nbv1.ConditionTypePhase {
+			phaseCond = &s.NooBaa.Status.Conditions[i]
+		}
+	}
+	if phaseCond == nil {
+		s.NooBaa.Status.Conditions = append(s.NooBaa.Status.Conditions, nbv1.SystemCondition{
 			Type:    nbv1.ConditionTypePhase,
 			Reason:  "ReconcileSetPhase",
 			Message: "Reconcile reached phase",
-		}}
+		})
+		phaseCond = &s.NooBaa.Status.Conditions[len(s.NooBaa.Status.Conditions)-1]
 	}
-	phaseCond := &s.NooBaa.Status.Conditions[0]
+	now := time.Now()
 	newPhaseStatus := nbv1.ConditionStatus(phase)
 	currstatus := phaseCond.Status
 	if currstatus != newPhaseStatus {
-		phaseCond.LastTransitionTime = metav1.Time{Time: time.Now()}
+		phaseCond.LastTransitionTime = metav1.Time{Time: now}
 	}
 	phaseCond.Status = newPhaseStatus
-	phaseCond.LastProbeTime = metav1.Time{Time: time.Now()}
+	phaseCond.LastProbeTime = metav1.Time{Time: now}
+	s.ObservePhase(phase, now)
+}
+
+// SetCondition sets the status of a condition type (other than the phase condition),
+// the condition is added if missing and the transition time is updated when the status changes.
+func (s *System) SetCondition(condType nbv1.ConditionType, status nbv1.ConditionStatus, reason string, message string) {
+	now := metav1.Now()
+	conditions := s.NooBaa.Status.Conditions
+	for i := range conditions {
+		c := &conditions[i]
+		if c.Type != condType {
+			continue
+		}
+		if c.Status != status {
+			c.LastTransitionTime = now
+		}
+		c.Status = status
+		c.Reason = reason
+		c.Message = message
+		c.LastProbeTime = now
+		return
+	}
+	s.NooBaa.Status.Conditions = append(conditions, nbv1.SystemCondition{
+		Type:               condType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	})
 }
 
 // Complete populates the noobaa status at the end of reconcile.
@@ -652,9 +1385,14 @@ func (s *System) UpdateSystemStatus() error {
 	return s.Client.Status().Update(s.Ctx, s.NooBaa)
 }
 
-// Own sets the object owner references to the noobaa system
-func (s *System) Own(obj metav1.Object) {
-	util.Panic(controllerutil.SetControllerReference(s.NooBaa, obj, s.Scheme))
+// Own sets the object owner references to the noobaa system.
+// Fails if the object is already controlled by another owner.
+func (s *System) Own(obj metav1.Object) error {
+	err := controllerutil.SetControllerReference(s.NooBaa, obj, s.Scheme)
+	if err != nil {
+		return NewPersistentError(err)
+	}
+	return nil
 }
 
 // GetObject gets an object by name from the request namespace.
@@ -662,32 +1400,100 @@ func (s *System) GetObject(name string, obj runtime.Object) error {
 	return s.Client.Get(s.Ctx, client.ObjectKey{Namespace: s.Request.Namespace, Name: name}, obj)
 }
 
-// ReconcileObject is a generic call to reconcile a kubernetes object
-// desiredFunc can be passed to modify the object before create/update.
-// Currently we ignore enforcing a desired state, but it might be needed on upgrades.
+// DeleteObjectIfExists deletes an object of the system only if it exists,
+// so that a disabled feature does not send a delete request on every reconcile.
+// An object whose kind is not served by the cluster (NoMatch) does not exist.
+func (s *System) DeleteObjectIfExists(obj runtime.Object) error {
+	reader := s.Client
+	if _, ok := obj.(*unstructured.Unstructured); ok && s.ClusterReader != nil {
+		// the manager cache does not support unstructured objects
+		reader = s.ClusterReader
+	}
+	objKey, err := client.ObjectKeyFromObject(obj)
+	if err != nil {
+		return err
+	}
+	err = reader.Get(s.Ctx, objKey, obj.DeepCopyObject())
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = util.KubeDeleteIfExists(s.Client, obj)
+	return err
+}
+
+// ReconcileObject is a generic call to reconcile a kubernetes object to its desired state.
+// The desired state is rendered from the object as loaded from the bundle template,
+// merged on top of the live object (see mergeDesiredState), and then desiredFunc
+// can be passed to modify the object from the system spec before create/update.
+// When the desired state differs from the live object (a spec change or a manual edit)
+// the object is updated and an event describes the changed fields.
 func (s *System) ReconcileObject(obj runtime.Object, desiredFunc func()) error {
 
 	kind := obj.GetObjectKind().GroupVersionKind().Kind
 	objMeta, _ := meta.Accessor(obj)
 	log := s.Logger.WithField("func", "ReconcileObject").WithField("kind", kind).WithField("name", objMeta.GetName())
 
-	s.Own(objMeta)
+	if err := s.Own(objMeta); err != nil {
+		return err
+	}
 
-	op, err := controllerutil.CreateOrUpdate(
-		s.Ctx, s.Client, obj.(runtime.Object),
-		func(obj runtime.Object) error {
-			if desiredFunc != nil {
-				desiredFunc()
-			}
-			return nil
-		},
-	)
+	live := reflect.New(reflect.TypeOf(obj).Elem()).Interface().(runtime.Object)
+	err := s.GetObject(objMeta.GetName(), live)
+	if errors.IsNotFound(err) {
+		if desiredFunc != nil {
+			desiredFunc()
+		}
+		err = s.Client.Create(s.Ctx, obj)
+		if err != nil {
+			log.Errorf("ReconcileObject Failed: %v", err)
+			return err
+		}
+		log.Infof("Done. op=created")
+		return nil
+	}
+	if err != nil {
+		log.Errorf("ReconcileObject Failed: %v", err)
+		return err
+	}
+
+	err = mergeDesiredState(obj, live)
+	if err != nil {
+		log.Errorf("ReconcileObject Failed: %v", err)
+		return err
+	}
+	if desiredFunc != nil {
+		desiredFunc()
+	}
+	preserveImmutableFields(obj, live)
+
+	diff, err := diffDesiredState(obj, live)
 	if err != nil {
 		log.Errorf("ReconcileObject Failed: %v", err)
 		return err
 	}
+	if len(diff) == 0 {
+		log.Infof("Done. op=unchanged")
+		return nil
+	}
 
-	log.Infof("Done. op=%s", op)
+	err = s.Client.Update(s.Ctx, obj)
+	if err != nil {
+		log.Errorf("ReconcileObject Failed: %v", err)
+		return err
+	}
+
+	if len(diff) > 10 {
+		diff = append(diff[:10], fmt.Sprintf("(and %d more)", len(diff)-10))
+	}
+	log.Infof("Done. op=updated diff=%v", diff)
+	if s.Recorder != nil {
+		s.Recorder.Eventf(s.NooBaa, corev1.EventTypeNormal, "DesiredStateApplied",
+			`Updated %s "%s" to the desired state, changed fields: %s`,
+			kind, objMeta.GetName(), strings.Join(diff, ", "))
+	}
 	return nil
 }
 
@@ -716,6 +1522,24 @@ func IsPersistentError(err error) bool {
 	return persistent
 }
 
+// ClassifyError returns a PersistentError for api errors that will not be resolved by retrying
+// without a change to the spec or to the environment (missing CRDs, invalid objects, missing permissions),
+// and returns other errors (timeouts, conflicts, server errors, etc.) as temporary errors.
+func ClassifyError(err error) error {
+	if err == nil || IsPersistentError(err) {
+		return err
+	}
+	if meta.IsNoMatchError(err) ||
+		errors.IsInvalid(err) ||
+		errors.IsBadRequest(err) ||
+		errors.IsForbidden(err) ||
+		errors.IsUnauthorized(err) ||
+		errors.IsMethodNotSupported(err) {
+		return NewPersistentError(err)
+	}
+	return err
+}
+
 // CombineErrors takes a list of errors and combines them to one.
 // Generally it will return the first non-nil error,
 // but if a persistent error is found it will be returned
@@ -760,3 +1584,70 @@ func randomHex(numBytes int) string {
 	util.Panic(err)
 	return hex.EncodeToString(randomBytes)
 }
+
+// ExternalDBTLSOptions returns the suffix to add to the external db url for verifying the db with the CA bundle
+// of the external db secret, or empty when the secret has no CA bundle or the url already sets a CA file.
+func (s *System) ExternalDBTLSOptions() string {
+	secret := s.ExternalDBSecret
+	if secret == nil || len(secret.Data["ca.crt"]) == 0 {
+		return ""
+	}
+	dbURL := string(secret.Data["db_url"])
+	if strings.Contains(dbURL, "tlsCAFile=") || strings.Contains(dbURL, "sslCA=") {
+		return ""
+	}
+	options := "tls=true&tlsCAFile=" + ExternalDBCAFile
+	if strings.Contains(dbURL, "?") {
+		return "&" + options
+	}
+	// the options require a slash after the hosts when the url has no database
+	hosts := strings.SplitN(dbURL, "://", 2)
+	if !strings.Contains(hosts[len(hosts)-1], "/") {
+		return "/?" + options
+	}
+	return "?" + options
+}
+
+// setEnv adds the env var to the container or replaces an existing var with the same name
+func setEnv(c *corev1.Container, env corev1.EnvVar) {
+	for i := range c.Env {
+		if c.Env[i].Name == env.Name {
+			c.Env[i] = env
+			return
+		}
+	}
+	c.Env = append(c.Env, env)
+}
+
+// removeEnv removes the env var from the container if it exists
+func removeEnv(c *corev1.Container, name string) {
+	var env []corev1.EnvVar
+	for _, e := range c.Env {
+		if e.Name != name {
+			env = append(env, e)
+		}
+	}
+	c.Env = env
+}
+
+// setVolume adds the volume to the pod or replaces an existing volume with the same name
+func setVolume(podSpec *corev1.PodSpec, vol corev1.Volume) {
+	for i := range podSpec.Volumes {
+		if podSpec.Volumes[i].Name == vol.Name {
+			podSpec.Volumes[i] = vol
+			return
+		}
+	}
+	podSpec.Volumes = append(podSpec.Volumes, vol)
+}
+
+// setVolumeMount adds the mount to the container or replaces an existing mount with the same name
+func setVolumeMount(c *corev1.Container, mount corev1.VolumeMount) {
+	for i := range c.VolumeMounts {
+		if c.VolumeMounts[i].Name == mount.Name {
+			c.VolumeMounts[i] = mount
+			return
+		}
+	}
+	c.VolumeMounts = append(c.VolumeMounts, mount)
+}
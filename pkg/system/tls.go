@@ -0,0 +1,272 @@
+package system
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// TLSHashAnnotation on the core pod template rolls the pod when the endpoint certificates change,
+	// since the server loads the certificates only on startup.
+	TLSHashAnnotation = "noobaa.io/tls-hash"
+)
+
+// CertificateGVK is the kind of the cert-manager certificate
+var CertificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+// TLSEndpoint describes an endpoint of the server that can serve a configured certificate
+type TLSEndpoint struct {
+	Name      string
+	Spec      *nbv1.TLSCertSpec
+	Service   *corev1.Service
+	Volume    string
+	MountPath string
+}
+
+// TLSEndpoints returns the endpoints of the server with the certificate spec of each
+func (s *System) TLSEndpoints() []TLSEndpoint {
+	return []TLSEndpoint{{
+		Name:      "s3",
+		Spec:      &s.NooBaa.Spec.TLS.S3,
+		Service:   s.ServiceS3,
+		Volume:    "s3-secret",
+		MountPath: "/etc/s3-secret",
+	}, {
+		Name:      "mgmt",
+		Spec:      &s.NooBaa.Spec.TLS.Mgmt,
+		Service:   s.ServiceMgmt,
+		Volume:    "mgmt-secret",
+		MountPath: "/etc/mgmt-secret",
+	}}
+}
+
+// TLSSecretName returns the name of the secret with the certificate of the endpoint,
+// or empty if the endpoint serves the self-signed certificate of the server.
+func (s *System) TLSSecretName(ep TLSEndpoint) string {
+	if ep.Spec.SecretRef != nil {
+		return ep.Spec.SecretRef.Name
+	}
+	if ep.Spec.CertManager != nil {
+		return s.Request.Name + "-" + ep.Name + "-tls"
+	}
+	return ""
+}
+
+// CheckSpecTLS checks the System.Spec.TLS properties
+func (s *System) CheckSpecTLS() error {
+
+	log := s.Logger.WithField("func", "CheckSpecTLS")
+
+	reject := func(format string, args ...interface{}) error {
+		msg := fmt.Sprintf(format, args...)
+		log.Errorf("%s", msg)
+		if s.Recorder != nil {
+			s.Recorder.Event(s.NooBaa, corev1.EventTypeWarning, "BadTLSSpec", msg)
+		}
+		s.SetPhase(nbv1.SystemPhaseRejected)
+		return NewPersistentError(fmt.Errorf("%s", msg))
+	}
+
+	for _, ep := range s.TLSEndpoints() {
+		if ep.Spec.SecretRef != nil && ep.Spec.CertManager != nil {
+			return reject(`TLS of %s endpoint cannot set both secretRef and certManager`, ep.Name)
+		}
+		if ep.Spec.SecretRef != nil && ep.Spec.SecretRef.Name == "" {
+			return reject(`TLS of %s endpoint is missing the secretRef name`, ep.Name)
+		}
+		if cm := ep.Spec.CertManager; cm != nil {
+			if cm.IssuerRef.Name == "" {
+				return reject(`TLS of %s endpoint is missing the certManager issuerRef name`, ep.Name)
+			}
+			if cm.IssuerRef.Kind != "" && cm.IssuerRef.Kind != "Issuer" && cm.IssuerRef.Kind != "ClusterIssuer" {
+				return reject(`TLS of %s endpoint has invalid certManager issuerRef kind "%s"`, ep.Name, cm.IssuerRef.Kind)
+			}
+		}
+	}
+	return nil
+}
+
+// ReconcileTLS requests the cert-manager certificates of the endpoints, and verifies that the
+// secrets of the endpoints have a valid certificate and key. The secrets are mounted to the
+// server by SetDesiredTLS and a change to any of them rolls the core pod.
+func (s *System) ReconcileTLS() error {
+
+	hash := sha256.New()
+	configured := false
+
+	for _, ep := range s.TLSEndpoints() {
+		if err := s.ReconcileCertificate(ep); err != nil {
+			return err
+		}
+
+		secretName := s.TLSSecretName(ep)
+		if secretName == "" {
+			continue
+		}
+		secret := &corev1.Secret{}
+		if err := s.GetObject(secretName, secret); err != nil {
+			if errors.IsNotFound(err) && s.Recorder != nil {
+				s.Recorder.Eventf(s.NooBaa, corev1.EventTypeWarning, "MissingTLSSecret",
+					`Waiting for TLS secret "%s" of %s endpoint`, secretName, ep.Name)
+			}
+			return err
+		}
+		if _, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey]); err != nil {
+			if s.Recorder != nil {
+				s.Recorder.Eventf(s.NooBaa, corev1.EventTypeWarning, "BadTLSSecret",
+					`TLS secret "%s" of %s endpoint has no valid certificate and key: %s`, secretName, ep.Name, err)
+			}
+			return fmt.Errorf(`TLS secret "%s" of %s endpoint has no valid certificate and key: %s`, secretName, ep.Name, err)
+		}
+		configured = true
+		hash.Write([]byte(ep.Name))
+		hash.Write(secret.Data[corev1.TLSCertKey])
+		hash.Write(secret.Data[corev1.TLSPrivateKeyKey])
+	}
+
+	s.TLSHash = ""
+	if configured {
+		s.TLSHash = hex.EncodeToString(hash.Sum(nil)[:8])
+	}
+	return nil
+}
+
+// ReconcileCertificate creates or updates the cert-manager Certificate of an endpoint,
+// or deletes it when the endpoint no longer uses cert-manager.
+func (s *System) ReconcileCertificate(ep TLSEndpoint) error {
+
+	log := s.Logger.WithField("func", "ReconcileCertificate")
+
+	// the manager cache does not support unstructured objects
+	reader := s.ClusterReader
+	if reader == nil {
+		reader = s.Client
+	}
+
+	cert := &unstructured.Unstructured{}
+	cert.SetGroupVersionKind(CertificateGVK)
+	cert.SetNamespace(s.Request.Namespace)
+	cert.SetName(s.Request.Name + "-" + ep.Name)
+
+	cm := ep.Spec.CertManager
+	if cm == nil {
+		return s.DeleteObjectIfExists(cert)
+	}
+
+	kind := cm.IssuerRef.Kind
+	if kind == "" {
+		kind = "Issuer"
+	}
+	svc := ep.Service.Name
+	ns := s.Request.Namespace
+	dnsNames := []interface{}{svc, svc + "." + ns, svc + "." + ns + ".svc", svc + "." + ns + ".svc.cluster.local"}
+	if ep.Name == "s3" {
+		alias := s.ServiceS3Alias.Name
+		dnsNames = append(dnsNames, alias+"."+ns, alias+"."+ns+".svc", alias+"."+ns+".svc.cluster.local")
+	}
+	for _, name := range cm.DNSNames {
+		dnsNames = append(dnsNames, name)
+	}
+	desired := map[string]interface{}{
+		"secretName": s.TLSSecretName(ep),
+		"dnsNames":   dnsNames,
+		"issuerRef": map[string]interface{}{
+			"name":  cm.IssuerRef.Name,
+			"kind":  kind,
+			"group": CertificateGVK.Group,
+		},
+	}
+
+	err := reader.Get(s.Ctx, client.ObjectKey{Namespace: ns, Name: cert.GetName()}, cert)
+	if meta.IsNoMatchError(err) {
+		msg := fmt.Sprintf(`TLS of %s endpoint requires cert-manager, but the Certificate kind is not installed`, ep.Name)
+		log.Errorf("%s", msg)
+		if s.Recorder != nil {
+			s.Recorder.Event(s.NooBaa, corev1.EventTypeWarning, "BadTLSSpec", msg)
+		}
+		return NewPersistentError(fmt.Errorf("%s", msg))
+	}
+	if errors.IsNotFound(err) {
+		log.Infof("Creating certificate %s for %s endpoint", cert.GetName(), ep.Name)
+		cert.Object["spec"] = desired
+		if err := s.Own(cert); err != nil {
+			return err
+		}
+		return s.Client.Create(s.Ctx, cert)
+	}
+	if err != nil {
+		return err
+	}
+
+	// compare only the fields that we set since cert-manager might default other fields
+	spec, _, _ := unstructured.NestedMap(cert.Object, "spec")
+	if spec == nil {
+		spec = map[string]interface{}{}
+	}
+	changed := false
+	for key, value := range desired {
+		if !reflect.DeepEqual(spec[key], value) {
+			spec[key] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	log.Infof("Updating certificate %s for %s endpoint", cert.GetName(), ep.Name)
+	cert.Object["spec"] = spec
+	return s.Client.Update(s.Ctx, cert)
+}
+
+// SetDesiredTLS mounts the secrets of the endpoint certificates to the server container
+func (s *System) SetDesiredTLS() {
+	if s.TLSHash == "" {
+		return
+	}
+	podTemplate := &s.CoreApp.Spec.Template
+	if podTemplate.Annotations == nil {
+		podTemplate.Annotations = map[string]string{}
+	}
+	podTemplate.Annotations[TLSHashAnnotation] = s.TLSHash
+	for _, ep := range s.TLSEndpoints() {
+		secretName := s.TLSSecretName(ep)
+		if secretName == "" {
+			continue
+		}
+		setVolume(&podTemplate.Spec, corev1.Volume{
+			Name: ep.Volume,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: secretName,
+					Items: []corev1.KeyToPath{
+						{Key: corev1.TLSCertKey, Path: corev1.TLSCertKey},
+						{Key: corev1.TLSPrivateKeyKey, Path: corev1.TLSPrivateKeyKey},
+					},
+				},
+			},
+		})
+		for i := range podTemplate.Spec.Containers {
+			c := &podTemplate.Spec.Containers[i]
+			if c.Name != "noobaa-server" {
+				continue
+			}
+			setVolumeMount(c, corev1.VolumeMount{
+				Name:      ep.Volume,
+				MountPath: ep.MountPath,
+				ReadOnly:  true,
+			})
+		}
+	}
+}
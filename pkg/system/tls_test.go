@@ -0,0 +1,39 @@
+package system
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestReconcileCertificateDisabled(t *testing.T) {
+	s := newTestSystem()
+	c := &deleteCountingClient{Client: s.Client}
+	s.Client = c
+	ep := s.TLSEndpoints()[0]
+
+	if err := s.ReconcileCertificate(ep); err != nil {
+		t.Fatal(err)
+	}
+	if c.Deletes != 0 {
+		t.Errorf("expected no delete request when the certificate does not exist, got %d", c.Deletes)
+	}
+
+	cert := &unstructured.Unstructured{}
+	cert.SetGroupVersionKind(CertificateGVK)
+	cert.SetNamespace(testNamespace)
+	cert.SetName(testName + "-" + ep.Name)
+	if err := c.Client.Create(s.Ctx, cert.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReconcileCertificate(ep); err != nil {
+		t.Fatal(err)
+	}
+	if c.Deletes != 1 {
+		t.Errorf("expected the certificate to be deleted, got %d delete requests", c.Deletes)
+	}
+	if err := c.Get(s.Ctx, client.ObjectKey{Namespace: testNamespace, Name: cert.GetName()}, cert); err == nil {
+		t.Errorf("expected the certificate to be deleted")
+	}
+}
@@ -0,0 +1,262 @@
+package system
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+	"github.com/noobaa/noobaa-operator/pkg/util"
+
+	dockerref "github.com/docker/distribution/reference"
+	semver "github.com/hashicorp/go-version"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// UpgradeBackupDir is the dir in the db volume of the core pod where upgrade backups are written
+	UpgradeBackupDir = "/data/upgrade-backups"
+
+	// MaxUpgradeBackups is the number of upgrade backup files to keep in the db volume
+	MaxUpgradeBackups = 3
+
+	// MaxUpgradeHistory is the number of entries to keep in Status.UpgradeHistory
+	MaxUpgradeHistory = 10
+)
+
+// ReconcileUpgrade runs the upgrade flow when the TargetImage is different than the Status.ActualImage.
+// The new image is validated (no downgrades unless Spec.ForceUpgrade), the running system is checked
+// to be healthy, and a db backup is taken, and only then the ActualImage is updated to roll the pods.
+// The upgrade is recorded in Status.UpgradeHistory and CompleteUpgrade will complete it once rolled.
+func (s *System) ReconcileUpgrade() error {
+
+	log := s.Logger.WithField("func", "ReconcileUpgrade")
+
+	fromImage := s.NooBaa.Status.ActualImage
+	toImage := s.TargetImage
+
+	// a new system starts directly with the target image
+	if fromImage == "" {
+		s.NooBaa.Status.ActualImage = toImage
+		return nil
+	}
+	if fromImage == toImage {
+		return nil
+	}
+
+	s.SetPhase(nbv1.SystemPhaseUpgrading)
+	entry := s.upgradeHistoryEntry(fromImage, toImage)
+	force := s.NooBaa.Spec.ForceUpgrade
+
+	reject := func(format string, args ...interface{}) error {
+		msg := fmt.Sprintf(format, args...)
+		log.Errorf("%s", msg)
+		if s.Recorder != nil {
+			s.Recorder.Event(s.NooBaa, corev1.EventTypeWarning, "UpgradeRejected", msg)
+		}
+		now := metav1.Now()
+		entry.State = nbv1.UpgradeStateRejected
+		entry.EndTime = &now
+		entry.Message = msg
+		s.SetPhase(nbv1.SystemPhaseRejected)
+		return NewPersistentError(fmt.Errorf("%s", msg))
+	}
+
+	wait := func(reason string, format string, args ...interface{}) error {
+		msg := fmt.Sprintf(format, args...)
+		log.Warnf("%s", msg)
+		if s.Recorder != nil {
+			s.Recorder.Event(s.NooBaa, corev1.EventTypeWarning, reason, msg)
+		}
+		entry.Message = msg
+		return fmt.Errorf("%s", msg)
+	}
+
+	if !force {
+		fromVersion := ImageVersion(fromImage)
+		toVersion := ImageVersion(toImage)
+		if fromVersion != nil && toVersion != nil && toVersion.LessThan(fromVersion) {
+			return reject(`Downgrade from "%s" to "%s" is not allowed (set spec.forceUpgrade to force it)`,
+				fromImage, toImage)
+		}
+		if err := s.CheckUpgradeHealth(); err != nil {
+			return wait("UpgradeWaiting", `Upgrade to "%s" is waiting for the system to be healthy: %s`,
+				toImage, err)
+		}
+	}
+
+	if entry.DBBackup == "" && s.NooBaa.Spec.DBType != nbv1.DBTypeExternal {
+		backup, err := s.BackupDBForUpgrade()
+		if err != nil {
+			return wait("UpgradeBackupFailed", `Upgrade to "%s" failed to backup the db: %s`, toImage, err)
+		}
+		entry.DBBackup = backup
+	}
+
+	log.Infof("Upgrading from \"%s\" to \"%s\" (db backup \"%s\")", fromImage, toImage, entry.DBBackup)
+	if s.Recorder != nil {
+		s.Recorder.Eventf(s.NooBaa, corev1.EventTypeNormal, "UpgradeStarted",
+			`Upgrading from "%s" to "%s"`, fromImage, toImage)
+	}
+	entry.State = nbv1.UpgradeStateRolling
+	entry.Message = "Rolling the core pods to the new image"
+	s.NooBaa.Status.ActualImage = toImage
+	return nil
+}
+
+// CompleteUpgrade completes an upgrade that is rolling once the core statefulset
+// is fully rolled to the new image and the operator is connected to the server.
+// Requires an initialized NBClient (see InitNooBaaClient).
+func (s *System) CompleteUpgrade() error {
+
+	log := s.Logger.WithField("func", "CompleteUpgrade")
+
+	history := s.NooBaa.Status.UpgradeHistory
+	if len(history) == 0 {
+		return nil
+	}
+	entry := &history[len(history)-1]
+	if entry.State != nbv1.UpgradeStateRolling {
+		return nil
+	}
+
+	s.SetPhase(nbv1.SystemPhaseUpgrading)
+
+	if err := s.CheckCoreAppRolled(); err != nil {
+		log.Infof("Waiting for upgrade rollout: %s", err)
+		return err
+	}
+
+	sys, err := s.NBClient.ReadSystemAPI()
+	if err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	entry.State = nbv1.UpgradeStateCompleted
+	entry.EndTime = &now
+	entry.Message = fmt.Sprintf("Upgraded to version %s", sys.Version)
+	log.Infof("✅ Upgrade completed to \"%s\" version %s", entry.ToImage, sys.Version)
+	if s.Recorder != nil {
+		s.Recorder.Eventf(s.NooBaa, corev1.EventTypeNormal, "UpgradeCompleted",
+			`Upgraded to "%s" version %s`, entry.ToImage, sys.Version)
+	}
+	return nil
+}
+
+// CheckUpgradeHealth checks that the running system is healthy before rolling it to a new image
+func (s *System) CheckUpgradeHealth() error {
+	if err := s.CheckCoreAppRolled(); err != nil {
+		return err
+	}
+	// the check runs before the mgmt service and the operator secret are reconciled,
+	// so the client is initialized with the service ports and the auth token read from the cluster
+	if _, err := util.KubeGet(s.Client, s.ServiceMgmt); err != nil {
+		return err
+	}
+	if err := s.LoadSecretOp(); err != nil {
+		return err
+	}
+	if err := s.InitNooBaaClient(); err != nil {
+		return err
+	}
+	if _, err := s.NBClient.ReadSystemAPI(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CheckCoreAppRolled checks that all the pods of the core statefulset are updated to its spec and ready
+func (s *System) CheckCoreAppRolled() error {
+	sts := &appsv1.StatefulSet{}
+	if err := s.GetObject(s.CoreApp.Name, sts); err != nil {
+		return err
+	}
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+	if sts.Status.ObservedGeneration < sts.Generation ||
+		sts.Status.CurrentRevision != sts.Status.UpdateRevision ||
+		sts.Status.UpdatedReplicas < replicas {
+		return fmt.Errorf("core pods updated %d/%d", sts.Status.UpdatedReplicas, replicas)
+	}
+	if sts.Status.ReadyReplicas < replicas {
+		return fmt.Errorf("core pods ready %d/%d", sts.Status.ReadyReplicas, replicas)
+	}
+	return nil
+}
+
+// BackupDBForUpgrade dumps the db to a file in the db volume of the core pod
+// and deletes older upgrade backups to keep MaxUpgradeBackups files.
+// Returns the path of the backup file in the mongodb container.
+func (s *System) BackupDBForUpgrade() (string, error) {
+
+	log := s.Logger.WithField("func", "BackupDBForUpgrade")
+
+	pod := &corev1.Pod{}
+	if err := s.GetObject(s.CoreApp.Name+"-0", pod); err != nil {
+		return "", err
+	}
+
+	backup := fmt.Sprintf("%s/nbcore-%s.archive.gz", UpgradeBackupDir, time.Now().UTC().Format("20060102-150405"))
+	script := fmt.Sprintf(
+		"mkdir -p %s && %smongodump --quiet --gzip --db nbcore --archive=%s && "+
+			"ls -1t %s/*.archive.gz | tail -n +%d | xargs -r rm -f",
+		UpgradeBackupDir, MongoBinDir, backup, UpgradeBackupDir, MaxUpgradeBackups+1)
+
+	log.Infof("Backup db to \"%s\" in pod %s ...", backup, pod.Name)
+	var stderr bytes.Buffer
+	err := util.KubeExec(pod, "mongodb", []string{"/bin/bash", "-c", script}, nil, nil, &stderr)
+	if err != nil {
+		return "", fmt.Errorf("%s %s", err, stderr.String())
+	}
+	return backup, nil
+}
+
+// upgradeHistoryEntry returns the last history entry if it is an incomplete upgrade
+// between the same images, or appends a new entry to the history.
+func (s *System) upgradeHistoryEntry(fromImage string, toImage string) *nbv1.UpgradeHistoryEntry {
+	history := s.NooBaa.Status.UpgradeHistory
+	if len(history) > 0 {
+		last := &history[len(history)-1]
+		if last.FromImage == fromImage &&
+			last.ToImage == toImage &&
+			last.State != nbv1.UpgradeStateCompleted {
+			last.State = nbv1.UpgradeStatePending
+			last.EndTime = nil
+			return last
+		}
+	}
+	history = append(history, nbv1.UpgradeHistoryEntry{
+		FromImage: fromImage,
+		ToImage:   toImage,
+		State:     nbv1.UpgradeStatePending,
+		StartTime: metav1.Now(),
+	})
+	if len(history) > MaxUpgradeHistory {
+		history = history[len(history)-MaxUpgradeHistory:]
+	}
+	s.NooBaa.Status.UpgradeHistory = history
+	return &history[len(history)-1]
+}
+
+// ImageVersion returns the semver of an image of the default image name,
+// or nil for custom images and tags that are not a version.
+func ImageVersion(image string) *semver.Version {
+	ref, err := dockerref.Parse(image)
+	if err != nil {
+		return nil
+	}
+	tagged, ok := ref.(dockerref.NamedTagged)
+	if !ok || tagged.Name() != ContainerImageName {
+		return nil
+	}
+	version, err := semver.NewVersion(tagged.Tag())
+	if err != nil {
+		return nil
+	}
+	return version
+}
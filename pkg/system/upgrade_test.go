@@ -0,0 +1,61 @@
+package system
+
+import (
+	"testing"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// TestUpgradeToReady runs a non forced upgrade of a running system through to Ready.
+// The health check and the completion of the upgrade call the server with the auth token
+// from the operator secret, which must be loaded before the client is initialized.
+func TestUpgradeToReady(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	fromImage := ContainerImageName + ":5.1.0"
+	toImage := ContainerImageName + ":5.2.0"
+	nooBaa := &nbv1.NooBaa{
+		Spec: nbv1.NooBaaSpec{
+			Image: &toImage,
+		},
+		Status: nbv1.NooBaaStatus{
+			Phase:       nbv1.SystemPhaseReady,
+			ActualImage: fromImage,
+		},
+	}
+	dbSecret := testExternalDB(nooBaa)
+	objs := append(testSystemObjects(t, srv, nooBaa), dbSecret)
+	c := newTestClient(objs...)
+	req := types.NamespacedName{Namespace: testNamespace, Name: testName}
+
+	s := New(req, c, scheme.Scheme, nil)
+	if _, err := s.Reconcile(); err != nil {
+		t.Fatalf("reconcile failed: %s", err)
+	}
+
+	result := &nbv1.NooBaa{}
+	if err := c.Get(s.Ctx, req, result); err != nil {
+		t.Fatal(err)
+	}
+	status := &result.Status
+	if status.Phase != nbv1.SystemPhaseReady {
+		t.Errorf("expected phase %s, got %s", nbv1.SystemPhaseReady, status.Phase)
+	}
+	if status.ActualImage != toImage {
+		t.Errorf("expected actual image %s, got %s", toImage, status.ActualImage)
+	}
+	if len(status.UpgradeHistory) != 1 {
+		t.Fatalf("expected 1 upgrade history entry, got %d", len(status.UpgradeHistory))
+	}
+	entry := status.UpgradeHistory[0]
+	if entry.State != nbv1.UpgradeStateCompleted || entry.FromImage != fromImage || entry.ToImage != toImage {
+		t.Errorf("expected completed upgrade from %s to %s, got %+v", fromImage, toImage, entry)
+	}
+	if entry.Message != "Upgraded to version "+srv.Version {
+		t.Errorf("unexpected upgrade message %q", entry.Message)
+	}
+}
@@ -2,17 +2,21 @@ package util
 
 import (
 	"context"
+	"io"
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 )
@@ -76,21 +80,14 @@ func KubeObject(text string) runtime.Object {
 func KubeApply(c client.Client, obj runtime.Object) bool {
 	objKey, _ := client.ObjectKeyFromObject(obj)
 	gvk := obj.GetObjectKind().GroupVersionKind()
-	clone := obj.DeepCopyObject()
-	err := c.Get(ctx, objKey, clone)
+	created, err := KubeUpsert(c, obj)
 	if err == nil {
-		err = c.Update(ctx, obj)
-		if err == nil {
-			logrus.Printf("✅ Updated: %s \"%s\"\n", gvk.Kind, objKey.Name)
-			return false
-		}
-	}
-	if errors.IsNotFound(err) {
-		err = c.Create(ctx, obj)
-		if err == nil {
+		if created {
 			logrus.Printf("✅ Created: %s \"%s\"\n", gvk.Kind, objKey.Name)
-			return true
+		} else {
+			logrus.Printf("✅ Updated: %s \"%s\"\n", gvk.Kind, objKey.Name)
 		}
+		return created
 	}
 	if errors.IsConflict(err) {
 		logrus.Printf("❌ Conflict: %s \"%s\": %s\n", gvk.Kind, objKey.Name, err)
@@ -105,27 +102,23 @@ func KubeApply(c client.Client, obj runtime.Object) bool {
 func KubeCreateSkipExisting(c client.Client, obj runtime.Object) bool {
 	objKey, _ := client.ObjectKeyFromObject(obj)
 	gvk := obj.GetObjectKind().GroupVersionKind()
-	clone := obj.DeepCopyObject()
-	err := c.Get(ctx, objKey, clone)
+	created, err := KubeCreateIfMissing(c, obj)
 	if err == nil {
-		logrus.Printf("✅ Already Exists: %s \"%s\"\n", gvk.Kind, objKey.Name)
-		return false
+		if created {
+			logrus.Printf("✅ Created: %s \"%s\"\n", gvk.Kind, objKey.Name)
+		} else {
+			logrus.Printf("✅ Already Exists: %s \"%s\"\n", gvk.Kind, objKey.Name)
+		}
+		return created
 	}
 	if meta.IsNoMatchError(err) {
 		logrus.Printf("❌ CRD Missing: %s \"%s\"\n", gvk.Kind, objKey.Name)
 		return false
 	}
 	if errors.IsNotFound(err) {
-		err = c.Create(ctx, obj)
-		if err == nil {
-			logrus.Printf("✅ Created: %s \"%s\"\n", gvk.Kind, objKey.Name)
-			return true
-		}
-		if errors.IsNotFound(err) {
-			logrus.Printf("❌ Namespace Missing: %s \"%s\": kubectl create ns %s\n",
-				gvk.Kind, objKey.Name, objKey.Namespace)
-			return true
-		}
+		logrus.Printf("❌ Namespace Missing: %s \"%s\": kubectl create ns %s\n",
+			gvk.Kind, objKey.Name, objKey.Namespace)
+		return true
 	}
 	if errors.IsConflict(err) {
 		logrus.Printf("❌ Conflict: %s \"%s\": %s\n", gvk.Kind, objKey.Name, err)
@@ -143,19 +136,19 @@ func KubeCreateSkipExisting(c client.Client, obj runtime.Object) bool {
 func KubeDelete(c client.Client, obj runtime.Object) bool {
 	objKey, _ := client.ObjectKeyFromObject(obj)
 	gvk := obj.GetObjectKind().GroupVersionKind()
-	err := c.Delete(ctx, obj)
+	deleted, err := KubeDeleteIfExists(c, obj)
 	if err == nil {
-		logrus.Printf("❌ Deleted: %s \"%s\"\n", gvk.Kind, objKey.Name)
-		return true
+		if deleted {
+			logrus.Printf("❌ Deleted: %s \"%s\"\n", gvk.Kind, objKey.Name)
+		} else {
+			logrus.Printf("❌ Not Found: %s \"%s\"\n", gvk.Kind, objKey.Name)
+		}
+		return deleted
 	}
 	if errors.IsConflict(err) {
 		logrus.Printf("❌ Conflict: %s \"%s\": %s\n", gvk.Kind, objKey.Name, err)
 		return false
 	}
-	if meta.IsNoMatchError(err) || errors.IsNotFound(err) {
-		logrus.Printf("❌ Not Found: %s \"%s\"\n", gvk.Kind, objKey.Name)
-		return false
-	}
 	Panic(err)
 	return false
 }
@@ -164,19 +157,19 @@ func KubeDelete(c client.Client, obj runtime.Object) bool {
 func KubeCheck(c client.Client, obj runtime.Object) bool {
 	objKey, _ := client.ObjectKeyFromObject(obj)
 	gvk := obj.GetObjectKind().GroupVersionKind()
-	err := c.Get(ctx, objKey, obj)
+	found, err := KubeGet(c, obj)
 	if err == nil {
-		logrus.Printf("✅ Exists: %s \"%s\"\n", gvk.Kind, objKey.Name)
-		return true
+		if found {
+			logrus.Printf("✅ Exists: %s \"%s\"\n", gvk.Kind, objKey.Name)
+		} else {
+			logrus.Printf("❌ Not Found: %s \"%s\"\n", gvk.Kind, objKey.Name)
+		}
+		return found
 	}
 	if meta.IsNoMatchError(err) {
 		logrus.Printf("❌ CRD Missing: %s \"%s\"\n", gvk.Kind, objKey.Name)
 		return false
 	}
-	if errors.IsNotFound(err) {
-		logrus.Printf("❌ Not Found: %s \"%s\"\n", gvk.Kind, objKey.Name)
-		return false
-	}
 	if errors.IsConflict(err) {
 		logrus.Printf("❌ Conflict: %s \"%s\": %s\n", gvk.Kind, objKey.Name, err)
 		return false
@@ -185,6 +178,101 @@ func KubeCheck(c client.Client, obj runtime.Object) bool {
 	return false
 }
 
+// The following variants return the errors instead of reporting and panicking,
+// which is needed for the controllers where an api error should not crash the operator.
+
+// KubeGet reads the object into obj and returns false if it was not found.
+func KubeGet(c client.Client, obj runtime.Object) (bool, error) {
+	objKey, _ := client.ObjectKeyFromObject(obj)
+	err := c.Get(ctx, objKey, obj)
+	if err == nil {
+		return true, nil
+	}
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// KubeCreateIfMissing creates the object if it does not exist yet and returns true if it was created.
+func KubeCreateIfMissing(c client.Client, obj runtime.Object) (bool, error) {
+	objKey, _ := client.ObjectKeyFromObject(obj)
+	clone := obj.DeepCopyObject()
+	err := c.Get(ctx, objKey, clone)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.IsNotFound(err) {
+		return false, err
+	}
+	err = c.Create(ctx, obj)
+	if errors.IsAlreadyExists(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// KubeUpsert updates the object if it exists or creates it otherwise, and returns true if it was created.
+func KubeUpsert(c client.Client, obj runtime.Object) (bool, error) {
+	objKey, _ := client.ObjectKeyFromObject(obj)
+	clone := obj.DeepCopyObject()
+	err := c.Get(ctx, objKey, clone)
+	if err == nil {
+		err = c.Update(ctx, obj)
+		if err == nil {
+			return false, nil
+		}
+	}
+	if errors.IsNotFound(err) {
+		err = c.Create(ctx, obj)
+		return err == nil, err
+	}
+	return false, err
+}
+
+// KubeDeleteIfExists deletes the object and returns false if it was not found.
+func KubeDeleteIfExists(c client.Client, obj runtime.Object) (bool, error) {
+	err := c.Delete(ctx, obj)
+	if err == nil {
+		return true, nil
+	}
+	if meta.IsNoMatchError(err) || errors.IsNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// KubeExec runs a command in a pod container (like kubectl exec) and streams the command stdio.
+// Streams that are passed as nil are not attached to the command.
+func KubeExec(pod *corev1.Pod, container string, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	config := KubeConfig()
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	req := clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdin:     stdin != nil,
+			Stdout:    stdout != nil,
+			Stderr:    stderr != nil,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+	return executor.Stream(remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+}
+
 // Panic is conviniently calling panic only if err is not nil
 func Panic(err error) {
 	if err != nil {
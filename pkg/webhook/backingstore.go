@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+
+	"github.com/sirupsen/logrus"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	atypes "sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+)
+
+// BackingStoreTypes are the supported store types
+var BackingStoreTypes = map[nbv1.StoreType]bool{
+	nbv1.StoreTypeAWSS3:              true,
+	nbv1.StoreTypeS3Compatible:       true,
+	nbv1.StoreTypeAzureBlob:          true,
+	nbv1.StoreTypeGoogleCloudStorage: true,
+}
+
+// BackingStoreValidator validates the spec of backing stores on create and update.
+// It checks the store type and its options, that the credentials secret exists,
+// and that the type and bucket name of an existing backing store are not changed.
+// The keys of the secret are not checked since the operator does not consume them yet.
+// Updates that do not change the spec and updates of a backing store that is being deleted are not validated,
+// so that the finalizer can be removed after the secret was deleted for example.
+type BackingStoreValidator struct {
+	Client    client.Client
+	Namespace string
+}
+
+// Handle implements admission.Handler
+func (v *BackingStoreValidator) Handle(ctx context.Context, req atypes.Request) atypes.Response {
+
+	if req.AdmissionRequest.Namespace != v.Namespace {
+		return allowed()
+	}
+
+	bs := &nbv1.BackingStore{}
+	if err := json.Unmarshal(req.AdmissionRequest.Object.Raw, bs); err != nil {
+		return denied("Failed to decode BackingStore: %s", err)
+	}
+	spec := &bs.Spec
+
+	if req.AdmissionRequest.Operation == admissionv1beta1.Update {
+		old := &nbv1.BackingStore{}
+		if err := json.Unmarshal(req.AdmissionRequest.OldObject.Raw, old); err != nil {
+			return denied("Failed to decode BackingStore: %s", err)
+		}
+		if bs.DeletionTimestamp != nil || reflect.DeepEqual(bs.Spec, old.Spec) {
+			return allowed()
+		}
+		if spec.Type != old.Spec.Type {
+			return denied(`BackingStore type is immutable (cannot change "%s" to "%s")`, old.Spec.Type, spec.Type)
+		}
+		if spec.BucketName != old.Spec.BucketName {
+			return denied(`BackingStore bucketName is immutable (cannot change "%s" to "%s")`, old.Spec.BucketName, spec.BucketName)
+		}
+	}
+
+	if !BackingStoreTypes[spec.Type] {
+		return denied(`Invalid BackingStore type "%s"`, spec.Type)
+	}
+	if spec.BucketName == "" {
+		return denied(`BackingStore of type "%s" requires bucketName`, spec.Type)
+	}
+
+	switch spec.Type {
+	case nbv1.StoreTypeAWSS3:
+	case nbv1.StoreTypeS3Compatible:
+		if spec.S3Options == nil || spec.S3Options.Endpoint == "" {
+			return denied(`BackingStore of type "%s" requires s3Options.endpoint`, spec.Type)
+		}
+	default:
+		if spec.S3Options != nil {
+			return denied(`BackingStore of type "%s" does not support s3Options`, spec.Type)
+		}
+	}
+	if spec.S3Options != nil {
+		switch spec.S3Options.SignatureVersion {
+		case "", nbv1.S3SignatureVersionV4, nbv1.S3SignatureVersionV2:
+		default:
+			return denied(`Invalid BackingStore s3Options.signatureVersion "%s"`, spec.S3Options.SignatureVersion)
+		}
+	}
+
+	if spec.Secret.Name == "" {
+		return denied(`BackingStore of type "%s" requires secret name`, spec.Type)
+	}
+	secretNamespace := spec.Secret.Namespace
+	if secretNamespace == "" {
+		secretNamespace = req.AdmissionRequest.Namespace
+	}
+	secret := &corev1.Secret{}
+	err := v.Client.Get(ctx, client.ObjectKey{Namespace: secretNamespace, Name: spec.Secret.Name}, secret)
+	if errors.IsNotFound(err) {
+		return denied(`BackingStore secret "%s" not found in namespace "%s"`, spec.Secret.Name, secretNamespace)
+	}
+	if err != nil {
+		// not denied since the secret might be valid, the api server might be temporarily unavailable
+		logrus.Warnf("Allowing BackingStore %s without checking secret %s/%s: %s",
+			bs.Name, secretNamespace, spec.Secret.Name, err)
+		return allowed()
+	}
+	return allowed()
+}
@@ -0,0 +1,187 @@
+package webhook
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+	nbv1alpha1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1"
+
+	"github.com/sirupsen/logrus"
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ConvertPath is the path of the conversion webhook in the CRDs
+	ConvertPath = "/convert"
+
+	// CABundleInterval is the interval for checking the CA bundle of the CRDs conversion webhook
+	CABundleInterval = time.Minute
+
+	// caCertName is the file name of the CA certificate that the webhook server writes to its CertDir
+	caCertName = "ca-cert.pem"
+)
+
+// ConvertedVersions are the served API versions of the noobaa.io types.
+// The storage version is nbv1 which is also the version used by the operator.
+var ConvertedVersions = []schema.GroupVersion{
+	nbv1.SchemeGroupVersion,
+	nbv1alpha1.SchemeGroupVersion,
+}
+
+// ConvertedCRDs are the CRDs with multiple versions that use the conversion webhook
+var ConvertedCRDs = []string{
+	"noobaas.noobaa.io",
+	"backingstores.noobaa.io",
+	"bucketclasses.noobaa.io",
+}
+
+// ConversionHandler converts noobaa.io objects between the served API versions.
+// All the versions have the same schema, so converting an object only changes its apiVersion.
+// The request and response of apiextensions v1 and v1beta1 ConversionReview are the same,
+// and the review is sent back with the same apiVersion as the request.
+type ConversionHandler struct{}
+
+// ServeHTTP implements http.Handler
+func (h *ConversionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	review := &apiextv1beta1.ConversionReview{}
+	err := json.NewDecoder(r.Body).Decode(review)
+	if err == nil && review.Request == nil {
+		err = fmt.Errorf("missing request")
+	}
+	if err != nil {
+		logrus.Errorf("Failed to decode ConversionReview: %s", err)
+		http.Error(w, fmt.Sprintf("Failed to decode ConversionReview: %s", err), http.StatusBadRequest)
+		return
+	}
+	review.Response = convert(review.Request)
+	review.Request = nil
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(review)
+	if err != nil {
+		logrus.Errorf("Failed to encode ConversionReview: %s", err)
+	}
+}
+
+// convert converts the objects of the request to the desired version
+func convert(req *apiextv1beta1.ConversionRequest) *apiextv1beta1.ConversionResponse {
+	res := &apiextv1beta1.ConversionResponse{UID: req.UID}
+	fail := func(format string, args ...interface{}) *apiextv1beta1.ConversionResponse {
+		message := fmt.Sprintf(format, args...)
+		logrus.Errorf("Conversion failed: %s", message)
+		res.ConvertedObjects = nil
+		res.Result = metav1.Status{Status: metav1.StatusFailure, Message: message}
+		return res
+	}
+
+	desired, err := schema.ParseGroupVersion(req.DesiredAPIVersion)
+	if err != nil {
+		return fail("Invalid desired API version %q: %s", req.DesiredAPIVersion, err)
+	}
+	if !isConvertedVersion(desired) {
+		return fail("Unsupported desired API version %q", req.DesiredAPIVersion)
+	}
+
+	for _, raw := range req.Objects {
+		obj := &unstructured.Unstructured{}
+		err := obj.UnmarshalJSON(raw.Raw)
+		if err != nil {
+			return fail("Failed to decode object: %s", err)
+		}
+		gv := obj.GroupVersionKind().GroupVersion()
+		if !isConvertedVersion(gv) {
+			return fail("Unsupported API version %q of %s %s", obj.GetAPIVersion(), obj.GetKind(), obj.GetName())
+		}
+		obj.SetAPIVersion(req.DesiredAPIVersion)
+		bytes, err := obj.MarshalJSON()
+		if err != nil {
+			return fail("Failed to encode %s %s: %s", obj.GetKind(), obj.GetName(), err)
+		}
+		res.ConvertedObjects = append(res.ConvertedObjects, runtime.RawExtension{Raw: bytes})
+	}
+
+	res.Result = metav1.Status{Status: metav1.StatusSuccess}
+	return res
+}
+
+func isConvertedVersion(gv schema.GroupVersion) bool {
+	for _, v := range ConvertedVersions {
+		if v == gv {
+			return true
+		}
+	}
+	return false
+}
+
+// caBundleInjector keeps the CA bundle of the CRDs conversion webhook up to date
+// with the self-signed certificate of the webhook server, which is generated on startup and refreshed before it expires.
+// Only the CRDs that point to the webhook service in the operator namespace are updated,
+// since the CRDs are cluster scoped and a single operator serves the conversion for all namespaces.
+type caBundleInjector struct {
+	Client    client.Client
+	Namespace string
+	CertDir   string
+}
+
+// Start implements manager.Runnable
+func (i *caBundleInjector) Start(stop <-chan struct{}) error {
+	wait.Until(i.inject, CABundleInterval, stop)
+	return nil
+}
+
+func (i *caBundleInjector) inject() {
+	caCert, err := ioutil.ReadFile(filepath.Join(i.CertDir, caCertName))
+	if os.IsNotExist(err) {
+		// the webhook server did not write its certificate yet
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to read webhook CA certificate: %s", err)
+		return
+	}
+	caBundle := base64.StdEncoding.EncodeToString(caCert)
+
+	for _, name := range ConvertedCRDs {
+		crd := &unstructured.Unstructured{}
+		crd.SetAPIVersion("apiextensions.k8s.io/v1")
+		crd.SetKind("CustomResourceDefinition")
+		err := i.Client.Get(context.TODO(), client.ObjectKey{Name: name}, crd)
+		if meta.IsNoMatchError(err) {
+			// older clusters without apiextensions v1 install the CRDs without webhook conversion
+			return
+		}
+		if err != nil {
+			logrus.Warnf("Failed to get CRD %s: %s", name, err)
+			continue
+		}
+		strategy, _, _ := unstructured.NestedString(crd.Object, "spec", "conversion", "strategy")
+		namespace, _, _ := unstructured.NestedString(crd.Object, "spec", "conversion", "webhook", "clientConfig", "service", "namespace")
+		current, _, _ := unstructured.NestedString(crd.Object, "spec", "conversion", "webhook", "clientConfig", "caBundle")
+		if strategy != "Webhook" || namespace != i.Namespace || current == caBundle {
+			continue
+		}
+		err = unstructured.SetNestedField(crd.Object, caBundle, "spec", "conversion", "webhook", "clientConfig", "caBundle")
+		if err == nil {
+			err = i.Client.Update(context.TODO(), crd)
+		}
+		if err != nil {
+			logrus.Warnf("Failed to update the conversion CA bundle of CRD %s: %s", name, err)
+			continue
+		}
+		logrus.Infof("✅ Updated the conversion CA bundle of CRD %s", name)
+	}
+}
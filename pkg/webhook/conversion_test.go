@@ -0,0 +1,331 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+	nbv1alpha1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// testSchema describes the json schema of a type, where the types of the noobaa api packages
+// are described by their fields and other types by their full name,
+// so that the same types in different api versions have the same description.
+func testSchema(t reflect.Type, seen map[reflect.Type]bool) string {
+	if !strings.HasPrefix(t.PkgPath(), "github.com/noobaa/noobaa-operator/pkg/apis/") {
+		if t.Name() != "" {
+			return t.PkgPath() + "." + t.Name()
+		}
+	} else if seen[t] {
+		return t.Name()
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + testSchema(t.Elem(), seen)
+	case reflect.Slice:
+		return "[]" + testSchema(t.Elem(), seen)
+	case reflect.Map:
+		return "map[" + testSchema(t.Key(), seen) + "]" + testSchema(t.Elem(), seen)
+	case reflect.Struct:
+		fields := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			fields = append(fields, fmt.Sprintf("%s %s", f.Tag.Get("json"), testSchema(f.Type, seen)))
+		}
+		return "{" + strings.Join(fields, "; ") + "}"
+	default:
+		return t.Kind().String()
+	}
+}
+
+// TestConvertedVersionsSameSchema checks that the served versions have the same schema,
+// since the conversion only changes the apiVersion of the objects.
+// Changing the schema of a version requires converting the fields in convert().
+func TestConvertedVersionsSameSchema(t *testing.T) {
+	pairs := [][2]interface{}{
+		{nbv1.NooBaa{}, nbv1alpha1.NooBaa{}},
+		{nbv1.BackingStore{}, nbv1alpha1.BackingStore{}},
+		{nbv1.BucketClass{}, nbv1alpha1.BucketClass{}},
+	}
+	for _, pair := range pairs {
+		v1 := testSchema(reflect.TypeOf(pair[0]), map[reflect.Type]bool{})
+		v1alpha1 := testSchema(reflect.TypeOf(pair[1]), map[reflect.Type]bool{})
+		if v1 != v1alpha1 {
+			t.Errorf("expected %T and %T to have the same schema:\n%s\n%s", pair[0], pair[1], v1, v1alpha1)
+		}
+	}
+}
+
+// testConvert converts the object to the desired version and decodes the converted object into out
+func testConvert(t *testing.T, obj runtime.Object, desired string, out runtime.Object) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := convert(&apiextv1beta1.ConversionRequest{
+		UID:               "test-uid",
+		DesiredAPIVersion: desired,
+		Objects:           []runtime.RawExtension{{Raw: raw}},
+	})
+	if res.UID != "test-uid" || res.Result.Status != metav1.StatusSuccess || len(res.ConvertedObjects) != 1 {
+		t.Fatalf("expected a successful conversion to %s, got %+v", desired, res)
+	}
+	if err := json.Unmarshal(res.ConvertedObjects[0].Raw, out); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testNooBaaV1alpha1 returns a v1alpha1 system with the spec and status fields set
+func testNooBaaV1alpha1() *nbv1alpha1.NooBaa {
+	image := "noobaa/noobaa-core:5.2.0"
+	storageClass := "gp2"
+	return &nbv1alpha1.NooBaa{
+		TypeMeta: metav1.TypeMeta{APIVersion: nbv1alpha1.SchemeGroupVersion.String(), Kind: "NooBaa"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   testNamespace,
+			Name:        "noobaa",
+			UID:         "test-uid",
+			Labels:      map[string]string{"app": "noobaa"},
+			Annotations: map[string]string{"noobaa.io/rotate-keys": "true"},
+			Finalizers:  []string{"noobaa.io/finalizer"},
+		},
+		Spec: nbv1alpha1.NooBaaSpec{
+			Image:            &image,
+			StorageClassName: &storageClass,
+			CoreResources: &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+			},
+			Tolerations:      []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "noobaa"}},
+			NodeSelector:     map[string]string{"node-role": "storage"},
+			DBType:           nbv1alpha1.DBTypeExternal,
+			ExternalDBSecret: &corev1.LocalObjectReference{Name: "external-db"},
+			CleanupPolicy:    nbv1alpha1.CleanupPolicyDeleteAll,
+			CABundle:         &corev1.LocalObjectReference{Name: "ca-bundle"},
+			Security: nbv1alpha1.SecuritySpec{
+				KMS: &nbv1alpha1.KMSSpec{Provider: nbv1alpha1.KMSProviderKubernetes, KeyName: "root-key"},
+			},
+		},
+		Status: nbv1alpha1.NooBaaStatus{
+			ObservedGeneration: 3,
+			Phase:              nbv1alpha1.SystemPhaseReady,
+			ActualImage:        image,
+			KMS:                &nbv1alpha1.KMSStatus{Provider: nbv1alpha1.KMSProviderKubernetes, KeyName: "root-key"},
+			Readme:             "readme",
+		},
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	nooBaa := testNooBaaV1alpha1()
+	converted := &nbv1.NooBaa{}
+	testConvert(t, nooBaa, nbv1.SchemeGroupVersion.String(), converted)
+	if converted.APIVersion != nbv1.SchemeGroupVersion.String() || converted.Kind != "NooBaa" {
+		t.Errorf("expected a v1 NooBaa, got %s %s", converted.APIVersion, converted.Kind)
+	}
+	if *converted.Spec.Image != *nooBaa.Spec.Image || converted.Status.KMS.KeyName != "root-key" {
+		t.Errorf("expected the fields to be converted, got %+v", converted)
+	}
+	back := &nbv1alpha1.NooBaa{}
+	testConvert(t, converted, nbv1alpha1.SchemeGroupVersion.String(), back)
+	if !reflect.DeepEqual(back, nooBaa) {
+		t.Errorf("expected the round trip to keep the NooBaa:\n%+v\n%+v", nooBaa, back)
+	}
+
+	backingStore := &nbv1.BackingStore{
+		TypeMeta:   metav1.TypeMeta{APIVersion: nbv1.SchemeGroupVersion.String(), Kind: "BackingStore"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "bs"},
+		Spec: nbv1.BackingStoreSpec{
+			Type:       nbv1.StoreTypeS3Compatible,
+			BucketName: "bucket",
+			Secret:     corev1.SecretReference{Name: "s3", Namespace: testNamespace},
+			S3Options:  &nbv1.S3Options{Endpoint: "https://s3.example.com", SignatureVersion: nbv1.S3SignatureVersionV4},
+		},
+	}
+	convertedStore := &nbv1alpha1.BackingStore{}
+	testConvert(t, backingStore, nbv1alpha1.SchemeGroupVersion.String(), convertedStore)
+	backStore := &nbv1.BackingStore{}
+	testConvert(t, convertedStore, nbv1.SchemeGroupVersion.String(), backStore)
+	if convertedStore.APIVersion != nbv1alpha1.SchemeGroupVersion.String() || !reflect.DeepEqual(backStore, backingStore) {
+		t.Errorf("expected the round trip to keep the BackingStore:\n%+v\n%+v", backingStore, backStore)
+	}
+
+	// the objects are kept as is except for the apiVersion
+	bucketClass := map[string]interface{}{
+		"apiVersion": nbv1alpha1.SchemeGroupVersion.String(),
+		"kind":       "BucketClass",
+		"metadata":   map[string]interface{}{"namespace": testNamespace, "name": "bc"},
+		"spec":       map[string]interface{}{},
+	}
+	convertedClass := &unstructured.Unstructured{}
+	testConvert(t, &unstructured.Unstructured{Object: bucketClass}, nbv1.SchemeGroupVersion.String(), convertedClass)
+	bucketClass["apiVersion"] = nbv1.SchemeGroupVersion.String()
+	if !reflect.DeepEqual(convertedClass.Object, bucketClass) {
+		t.Errorf("expected only the apiVersion of the BucketClass to change, got %v", convertedClass.Object)
+	}
+}
+
+func TestConvertUnsupported(t *testing.T) {
+	raw, err := json.Marshal(testNooBaaV1alpha1())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := json.Marshal(&corev1.ConfigMap{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		desired string
+		objects [][]byte
+	}{
+		{"unknown desired version", "noobaa.io/v2", [][]byte{raw}},
+		{"desired version of other group", "v1", [][]byte{raw}},
+		{"invalid desired version", "noobaa.io/v1/v2", [][]byte{raw}},
+		{"object of other group", nbv1.SchemeGroupVersion.String(), [][]byte{raw, other}},
+		{"invalid object", nbv1.SchemeGroupVersion.String(), [][]byte{[]byte("{")}},
+	}
+	for _, test := range tests {
+		req := &apiextv1beta1.ConversionRequest{UID: "test-uid", DesiredAPIVersion: test.desired}
+		for _, obj := range test.objects {
+			req.Objects = append(req.Objects, runtime.RawExtension{Raw: obj})
+		}
+		res := convert(req)
+		if res.UID != "test-uid" || res.Result.Status != metav1.StatusFailure || res.ConvertedObjects != nil {
+			t.Errorf("%s: expected the conversion to fail, got %+v", test.name, res)
+		}
+	}
+}
+
+func TestConversionHandler(t *testing.T) {
+	raw, err := json.Marshal(testNooBaaV1alpha1())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(&ConversionHandler{})
+	defer srv.Close()
+
+	// the review is sent back with the apiVersion of the request (apiextensions v1 or v1beta1)
+	for _, apiVersion := range []string{"apiextensions.k8s.io/v1", "apiextensions.k8s.io/v1beta1"} {
+		review := &apiextv1beta1.ConversionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: apiVersion, Kind: "ConversionReview"},
+			Request: &apiextv1beta1.ConversionRequest{
+				UID:               types.UID("test-uid"),
+				DesiredAPIVersion: nbv1.SchemeGroupVersion.String(),
+				Objects:           []runtime.RawExtension{{Raw: raw}},
+			},
+		}
+		body, err := json.Marshal(review)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.Post(srv.URL+ConvertPath, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply := &apiextv1beta1.ConversionReview{}
+		err = json.NewDecoder(res.Body).Decode(reply)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply.APIVersion != apiVersion || reply.Request != nil || reply.Response == nil ||
+			reply.Response.UID != "test-uid" || len(reply.Response.ConvertedObjects) != 1 {
+			t.Errorf("expected a %s review with the converted object, got %+v", apiVersion, reply)
+		}
+	}
+
+	res, err := http.Post(srv.URL+ConvertPath, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected bad request for a review without a request, got %s", res.Status)
+	}
+}
+
+// testCRD returns a CRD with the conversion webhook of the operator in the namespace
+func testCRD(name string, namespace string, caBundle string) *unstructured.Unstructured {
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"conversion": map[string]interface{}{
+				"strategy": "Webhook",
+				"webhook": map[string]interface{}{
+					"clientConfig": map[string]interface{}{
+						"caBundle": caBundle,
+						"service":  map[string]interface{}{"name": ServiceName, "namespace": namespace, "path": ConvertPath},
+					},
+				},
+			},
+		},
+	}}
+	crd.SetAPIVersion("apiextensions.k8s.io/v1")
+	crd.SetKind("CustomResourceDefinition")
+	crd.SetName(name)
+	return crd
+}
+
+func TestCABundleInjector(t *testing.T) {
+	certDir, err := ioutil.TempDir("", "noobaa-webhook-cert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(certDir)
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme,
+		testCRD(ConvertedCRDs[0], testNamespace, ""),
+		testCRD(ConvertedCRDs[1], testNamespace, "b2xk"),
+		testCRD(ConvertedCRDs[2], "other", ""),
+	)
+	i := &caBundleInjector{Client: c, Namespace: testNamespace, CertDir: certDir}
+	caBundle := func(name string) string {
+		crd := testCRD(name, "", "")
+		if err := c.Get(context.TODO(), client.ObjectKey{Name: name}, crd); err != nil {
+			t.Fatal(err)
+		}
+		value, _, _ := unstructured.NestedString(crd.Object, "spec", "conversion", "webhook", "clientConfig", "caBundle")
+		return value
+	}
+
+	// nothing to inject before the server writes its certificate
+	i.inject()
+	if caBundle(ConvertedCRDs[0]) != "" {
+		t.Errorf("expected no CA bundle before the certificate is written")
+	}
+
+	caCert := []byte("-----BEGIN CERTIFICATE-----\ntest\n-----END CERTIFICATE-----\n")
+	if err := ioutil.WriteFile(filepath.Join(certDir, caCertName), caCert, 0600); err != nil {
+		t.Fatal(err)
+	}
+	i.inject()
+	expected := base64.StdEncoding.EncodeToString(caCert)
+	if caBundle(ConvertedCRDs[0]) != expected || caBundle(ConvertedCRDs[1]) != expected {
+		t.Errorf("expected the CA bundle of the certificate in the CRDs of the namespace")
+	}
+	if caBundle(ConvertedCRDs[2]) != "" {
+		t.Errorf("expected the CRD of the operator of another namespace to be kept")
+	}
+}
@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+	"github.com/noobaa/noobaa-operator/pkg/system"
+
+	"github.com/sirupsen/logrus"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	atypes "sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+)
+
+// NooBaaValidator validates the spec of noobaa systems on create and update
+// with the same checks that the reconcile runs before it changes anything.
+// Only persistent errors (which the reconcile would reject) deny the request,
+// so a system can still be created before its referenced secrets for example.
+// Updates that do not change the spec (such as the finalizer and annotations that the operator updates)
+// and updates of a system that is being deleted are not validated.
+type NooBaaValidator struct {
+	Client    client.Client
+	Scheme    *runtime.Scheme
+	Namespace string
+}
+
+// Handle implements admission.Handler
+func (v *NooBaaValidator) Handle(ctx context.Context, req atypes.Request) atypes.Response {
+
+	if req.AdmissionRequest.Namespace != v.Namespace {
+		return allowed()
+	}
+
+	nooBaa := &nbv1.NooBaa{}
+	if err := json.Unmarshal(req.AdmissionRequest.Object.Raw, nooBaa); err != nil {
+		return denied("Failed to decode NooBaa: %s", err)
+	}
+
+	if req.AdmissionRequest.Operation == admissionv1beta1.Update {
+		old := &nbv1.NooBaa{}
+		if err := json.Unmarshal(req.AdmissionRequest.OldObject.Raw, old); err != nil {
+			return denied("Failed to decode NooBaa: %s", err)
+		}
+		if nooBaa.DeletionTimestamp != nil || reflect.DeepEqual(nooBaa.Spec, old.Spec) {
+			return allowed()
+		}
+	}
+
+	nooBaa.Namespace = req.AdmissionRequest.Namespace
+	s := system.New(types.NamespacedName{Namespace: nooBaa.Namespace, Name: nooBaa.Name}, v.Client, v.Scheme, nil)
+	s.Logger = logrus.WithFields(logrus.Fields{"ns": nooBaa.Namespace, "sys": nooBaa.Name, "webhook": "noobaa"})
+	s.NooBaa = nooBaa
+
+	checks := []func() error{
+		s.CheckSpecImage,
+		s.CheckSpecDB,
+		s.CheckSpecKMS,
+		s.CheckSpecTLS,
+	}
+	for _, check := range checks {
+		err := check()
+		if err == nil {
+			continue
+		}
+		if system.IsPersistentError(err) {
+			return denied("%s", err)
+		}
+		s.Logger.Warnf("Allowing NooBaa with temporary error: %s", err)
+	}
+	return allowed()
+}
@@ -0,0 +1,196 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	crwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/builder"
+	atypes "sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+)
+
+const (
+	// ServerPort is the port of the webhook server in the operator pod
+	ServerPort = 8443
+
+	// ServerCertDir is where the server writes its self-signed serving certificate.
+	// The certificate is generated on startup and refreshed before it expires,
+	// and the CA bundle of the webhook configuration is updated accordingly.
+	ServerCertDir = "/tmp/noobaa-webhook/cert"
+
+	// ServiceName is the name of the service in front of the webhook server
+	ServiceName = "noobaa-webhook"
+
+	// EnableEnv can be set to "false" to run the operator without the webhook server,
+	// for example when running the operator outside of the cluster.
+	EnableEnv = "ENABLE_WEBHOOKS"
+)
+
+// ValidatingWebhookConfigName returns the name of the cluster scoped webhook configuration of the operator
+// in the namespace. Every namespaced operator installs its own configuration.
+func ValidatingWebhookConfigName(namespace string) string {
+	return "noobaa-validation-" + namespace
+}
+
+// Add creates the webhook server with the admission and conversion webhooks and adds it to the Manager.
+// The server installs its service and webhook configuration on startup,
+// and the CA bundle of the CRDs conversion webhook is kept up to date with the server certificate.
+func Add(mgr manager.Manager, namespace string) error {
+
+	if os.Getenv(EnableEnv) == "false" {
+		logrus.Infof("Webhook server is disabled by %s", EnableEnv)
+		return nil
+	}
+
+	// uncached client since the webhook configuration and CRDs are cluster scoped (the manager cache is namespaced)
+	// and the secrets of backing stores might be in other namespaces
+	reader, err := client.New(mgr.GetConfig(), client.Options{
+		Scheme: mgr.GetScheme(),
+		Mapper: mgr.GetRESTMapper(),
+	})
+	if err != nil {
+		return err
+	}
+
+	ignore := admissionregistrationv1beta1.Ignore
+
+	noobaaWebhook, err := builder.NewWebhookBuilder().
+		Name("noobaa.noobaa.io").
+		Validating().
+		Path("/validate-noobaa").
+		Rules(rules("noobaas")).
+		FailurePolicy(ignore).
+		WithManager(mgr).
+		Handlers(&NooBaaValidator{Client: reader, Scheme: mgr.GetScheme(), Namespace: namespace}).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	backingStoreWebhook, err := builder.NewWebhookBuilder().
+		Name("backingstore.noobaa.io").
+		Validating().
+		Path("/validate-backingstore").
+		Rules(rules("backingstores")).
+		FailurePolicy(ignore).
+		WithManager(mgr).
+		Handlers(&BackingStoreValidator{Client: reader, Namespace: namespace}).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	server, err := crwebhook.NewServer("noobaa-webhook-server", &serverManager{Manager: mgr}, crwebhook.ServerOptions{
+		Port:    ServerPort,
+		CertDir: ServerCertDir,
+		BootstrapOptions: &crwebhook.BootstrapOptions{
+			ValidatingWebhookConfigName: ValidatingWebhookConfigName(namespace),
+			Service: &crwebhook.Service{
+				Name:      ServiceName,
+				Namespace: namespace,
+				Selectors: map[string]string{"noobaa-operator": "deployment"},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// register all the webhooks at once since every call adds the server to the manager
+	err = server.Register(noobaaWebhook, backingStoreWebhook)
+	if err != nil {
+		return err
+	}
+
+	// the manager injects its cached client on register, so replace it with the uncached one
+	server.Client = reader
+
+	server.Handle(ConvertPath, &ConversionHandler{})
+	return mgr.Add(&caBundleInjector{Client: reader, Namespace: namespace, CertDir: ServerCertDir})
+}
+
+// serverManager adds the webhook server to the manager as a tolerantServer
+type serverManager struct {
+	manager.Manager
+}
+
+// Add implements manager.Manager
+func (m *serverManager) Add(r manager.Runnable) error {
+	if server, ok := r.(*crwebhook.Server); ok {
+		r = &tolerantServer{Server: server}
+	}
+	return m.Manager.Add(r)
+}
+
+// tolerantServer runs the webhook server without failing the manager, which would exit the operator.
+// Installing the webhook configuration fails on clusters that do not serve admissionregistration.k8s.io/v1beta1,
+// and then the server runs without it, so the specs are only validated by the reconcile.
+type tolerantServer struct {
+	*crwebhook.Server
+}
+
+// Start implements manager.Runnable
+func (t *tolerantServer) Start(stop <-chan struct{}) error {
+	err := t.Server.Start(stop)
+	if err != nil && t.DisableWebhookConfigInstaller != nil && !*t.DisableWebhookConfigInstaller {
+		logrus.Errorf("Failed installing the webhook configuration, running without admission webhooks: %s", err)
+		disabled := true
+		t.DisableWebhookConfigInstaller = &disabled
+		err = t.Server.Start(stop)
+	}
+	if err != nil {
+		logrus.Errorf("Webhook server failed: %s", err)
+		<-stop
+	}
+	return nil
+}
+
+// rules returns the admission rules of a noobaa.io resource for all the served versions,
+// since every version is validated the same
+func rules(resource string) admissionregistrationv1beta1.RuleWithOperations {
+	versions := []string{}
+	for _, gv := range ConvertedVersions {
+		versions = append(versions, gv.Version)
+	}
+	return admissionregistrationv1beta1.RuleWithOperations{
+		Operations: []admissionregistrationv1beta1.OperationType{
+			admissionregistrationv1beta1.Create,
+			admissionregistrationv1beta1.Update,
+		},
+		Rule: admissionregistrationv1beta1.Rule{
+			APIGroups:   []string{nbv1.SchemeGroupVersion.Group},
+			APIVersions: versions,
+			Resources:   []string{resource},
+		},
+	}
+}
+
+// allowed returns an admission response that allows the request
+func allowed() atypes.Response {
+	return admission.ValidationResponse(true, "")
+}
+
+// denied returns an admission response that denies the request.
+// The message is shown to the user by the api server (for example on kubectl apply).
+func denied(format string, args ...interface{}) atypes.Response {
+	return atypes.Response{
+		Response: &admissionv1beta1.AdmissionResponse{
+			Allowed: false,
+			Result: &metav1.Status{
+				Code:    http.StatusForbidden,
+				Reason:  metav1.StatusReasonForbidden,
+				Message: fmt.Sprintf(format, args...),
+			},
+		},
+	}
+}
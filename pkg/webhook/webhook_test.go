@@ -0,0 +1,169 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/noobaa/noobaa-operator/pkg/apis"
+	nbv1 "github.com/noobaa/noobaa-operator/pkg/apis/noobaa/v1"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	atypes "sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+)
+
+const testNamespace = "test-noobaa"
+
+func init() {
+	// the fake client decodes with the client-go scheme
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		panic(err)
+	}
+}
+
+// testRequest returns an admission request of the operation on the object (and the old object on update)
+func testRequest(t *testing.T, op admissionv1beta1.Operation, obj interface{}, old interface{}) atypes.Request {
+	req := &admissionv1beta1.AdmissionRequest{Operation: op, Namespace: testNamespace}
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Object = runtime.RawExtension{Raw: raw}
+	if old != nil {
+		raw, err := json.Marshal(old)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.OldObject = runtime.RawExtension{Raw: raw}
+	}
+	return atypes.Request{AdmissionRequest: req}
+}
+
+// testAllowed checks that the response allows or denies the request as expected
+func testAllowed(t *testing.T, name string, res atypes.Response, expected bool) {
+	if res.Response.Allowed == expected {
+		return
+	}
+	message := ""
+	if res.Response.Result != nil {
+		message = res.Response.Result.Message
+	}
+	t.Errorf("%s: expected allowed=%v, got allowed=%v %s", name, expected, res.Response.Allowed, message)
+}
+
+func testNooBaa(image string) *nbv1.NooBaa {
+	return &nbv1.NooBaa{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "noobaa"},
+		Spec:       nbv1.NooBaaSpec{Image: &image},
+	}
+}
+
+func TestNooBaaValidator(t *testing.T) {
+	v := &NooBaaValidator{Client: fake.NewFakeClientWithScheme(scheme.Scheme), Scheme: scheme.Scheme, Namespace: testNamespace}
+	ctx := context.TODO()
+
+	valid := testNooBaa("noobaa/noobaa-core:5.2.0")
+	invalid := testNooBaa("noobaa/noobaa-core:4.0")
+
+	testAllowed(t, "create valid", v.Handle(ctx, testRequest(t, admissionv1beta1.Create, valid, nil)), true)
+	testAllowed(t, "create invalid image", v.Handle(ctx, testRequest(t, admissionv1beta1.Create, invalid, nil)), false)
+	testAllowed(t, "update to invalid image", v.Handle(ctx, testRequest(t, admissionv1beta1.Update, invalid, valid)), false)
+
+	// metadata updates of an existing system with a spec that is no longer valid are allowed,
+	// for example when the operator adds a finalizer or removes an annotation
+	updated := invalid.DeepCopy()
+	updated.Finalizers = []string{"noobaa.io/finalizer"}
+	testAllowed(t, "update metadata", v.Handle(ctx, testRequest(t, admissionv1beta1.Update, updated, invalid)), true)
+
+	deleting := testNooBaa("noobaa/noobaa-core:4.0")
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+	testAllowed(t, "update deleting", v.Handle(ctx, testRequest(t, admissionv1beta1.Update, deleting, valid)), true)
+
+	other := invalid.DeepCopy()
+	other.Namespace = "other"
+	req := testRequest(t, admissionv1beta1.Create, other, nil)
+	req.AdmissionRequest.Namespace = "other"
+	testAllowed(t, "other namespace", v.Handle(ctx, req), true)
+}
+
+func testBackingStore(storeType nbv1.StoreType, bucketName string, secretName string) *nbv1.BackingStore {
+	return &nbv1.BackingStore{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "bs"},
+		Spec: nbv1.BackingStoreSpec{
+			Type:       storeType,
+			BucketName: bucketName,
+			Secret:     corev1.SecretReference{Name: secretName},
+		},
+	}
+}
+
+func TestBackingStoreValidator(t *testing.T) {
+	awsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "aws"},
+		Data:       map[string][]byte{"AWS_ACCESS_KEY_ID": []byte("id"), "AWS_SECRET_ACCESS_KEY": []byte("key")},
+	}
+	v := &BackingStoreValidator{Client: fake.NewFakeClientWithScheme(scheme.Scheme, awsSecret), Namespace: testNamespace}
+	ctx := context.TODO()
+
+	valid := testBackingStore(nbv1.StoreTypeAWSS3, "bucket", "aws")
+	s3Compatible := testBackingStore(nbv1.StoreTypeS3Compatible, "bucket", "aws")
+	s3Compatible.Spec.S3Options = &nbv1.S3Options{Endpoint: "https://s3.example.com", SignatureVersion: nbv1.S3SignatureVersionV2}
+	badSignature := s3Compatible.DeepCopy()
+	badSignature.Spec.S3Options.SignatureVersion = "v3"
+	azureOptions := testBackingStore(nbv1.StoreTypeAzureBlob, "bucket", "aws")
+	azureOptions.Spec.S3Options = &nbv1.S3Options{}
+
+	creates := []struct {
+		name     string
+		bs       *nbv1.BackingStore
+		expected bool
+	}{
+		{"valid", valid, true},
+		{"s3 compatible", s3Compatible, true},
+		{"invalid type", testBackingStore("ftp", "bucket", "aws"), false},
+		{"missing bucket", testBackingStore(nbv1.StoreTypeAWSS3, "", "aws"), false},
+		{"missing endpoint", testBackingStore(nbv1.StoreTypeS3Compatible, "bucket", "aws"), false},
+		{"invalid signature version", badSignature, false},
+		{"s3 options of azure", azureOptions, false},
+		{"missing secret name", testBackingStore(nbv1.StoreTypeAWSS3, "bucket", ""), false},
+		{"secret not found", testBackingStore(nbv1.StoreTypeAWSS3, "bucket", "missing"), false},
+		{"google cloud storage", testBackingStore(nbv1.StoreTypeGoogleCloudStorage, "bucket", "aws"), true},
+	}
+	for _, test := range creates {
+		testAllowed(t, test.name, v.Handle(ctx, testRequest(t, admissionv1beta1.Create, test.bs, nil)), test.expected)
+	}
+
+	renamed := valid.DeepCopy()
+	renamed.Spec.BucketName = "other-bucket"
+	testAllowed(t, "update bucket name", v.Handle(ctx, testRequest(t, admissionv1beta1.Update, renamed, valid)), false)
+	retyped := valid.DeepCopy()
+	retyped.Spec.Type = nbv1.StoreTypeS3Compatible
+	retyped.Spec.S3Options = &nbv1.S3Options{Endpoint: "https://s3.example.com"}
+	testAllowed(t, "update type", v.Handle(ctx, testRequest(t, admissionv1beta1.Update, retyped, valid)), false)
+
+	// the finalizer of a backing store can be removed after its secret was deleted
+	orphan := testBackingStore(nbv1.StoreTypeAWSS3, "bucket", "missing")
+	now := metav1.Now()
+	deleting := orphan.DeepCopy()
+	deleting.DeletionTimestamp = &now
+	testAllowed(t, "update deleting", v.Handle(ctx, testRequest(t, admissionv1beta1.Update, deleting, orphan)), true)
+	labeled := orphan.DeepCopy()
+	labeled.Labels = map[string]string{"app": "noobaa"}
+	testAllowed(t, "update metadata", v.Handle(ctx, testRequest(t, admissionv1beta1.Update, labeled, orphan)), true)
+}
+
+func TestRules(t *testing.T) {
+	r := rules("backingstores")
+	if len(r.Rule.APIVersions) != 2 || r.Rule.APIVersions[0] != "v1" || r.Rule.APIVersions[1] != "v1alpha1" {
+		t.Errorf("expected the rules of all the served versions, got %v", r.Rule.APIVersions)
+	}
+	if len(r.Rule.Resources) != 1 || r.Rule.Resources[0] != "backingstores" || r.Rule.APIGroups[0] != nbv1.SchemeGroupVersion.Group {
+		t.Errorf("expected the rules of noobaa.io backingstores, got %v %v", r.Rule.APIGroups, r.Rule.Resources)
+	}
+}